@@ -0,0 +1,225 @@
+package changeset
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// AssocKind is the kind of an association declared on a relation field.
+type AssocKind uint8
+
+const (
+	BelongsTo AssocKind = iota + 1
+	HasOne
+	HasMany
+	ManyToMany
+)
+
+var assocKinds = map[string]AssocKind{
+	"belongs_to":   BelongsTo,
+	"has_one":      HasOne,
+	"has_many":     HasMany,
+	"many_to_many": ManyToMany,
+}
+
+func (k AssocKind) String() string {
+	for name, kind := range assocKinds {
+		if kind == k {
+			return name
+		}
+	}
+	return "none"
+}
+
+// parseAssoc reads the association declared by the `ecto` tag of a relation field:
+// `ecto:"belongs_to,foreign_key:AccountId,references:Id"`, `ecto:"has_many,foreign_key:AuthorId"`
+// or `ecto:"many_to_many,join_table:posts_tags,join_keys:PostId|TagId"`.
+// The foreign key of a belongs_to is <Field>Id on the schema, the one of a has_one or has_many is
+// <Schema>Id on the related schema, references is the primary key when it isn't declared.
+func (m *Meta) parseAssoc(sf reflect.StructField, rel *RelationMeta) {
+	rel.owner = m
+	for _, opt := range strings.Split(sf.Tag.Get("ecto"), ",") {
+		key, value := opt, ""
+		if i := strings.Index(opt, ":"); i >= 0 {
+			key, value = opt[:i], opt[i+1:]
+		}
+		key = strings.TrimSpace(key)
+		if kind, ok := assocKinds[key]; ok {
+			rel.Assoc = kind
+			continue
+		}
+		switch key {
+		case "foreign_key":
+			rel.ForeignKey = value
+		case "references":
+			rel.References = value
+		case "join_table":
+			rel.JoinTable = value
+		case "join_keys":
+			keys := strings.Split(value, "|")
+			if len(keys) != 2 {
+				panic(fmt.Sprintf("changeset: join_keys of %v.%v needs two keys separated by |", m.Type.Name(), sf.Name))
+			}
+			rel.JoinKeys = [2]string{keys[0], keys[1]}
+		}
+	}
+	if rel.Assoc == 0 {
+		return
+	}
+	if rel.Many != (rel.Assoc == HasMany || rel.Assoc == ManyToMany) {
+		panic(fmt.Sprintf("changeset: %v %v.%v doesn't fit a field of type %v", rel.Assoc, m.Type.Name(), sf.Name, sf.Type))
+	}
+	switch rel.Assoc {
+	case BelongsTo:
+		if rel.ForeignKey == "" {
+			rel.ForeignKey = rel.Name + "Id"
+		}
+	case HasOne, HasMany:
+		if rel.ForeignKey == "" {
+			rel.ForeignKey = m.Type.Name() + "Id"
+		}
+	case ManyToMany:
+		if rel.JoinTable == "" {
+			panic(fmt.Sprintf("changeset: many_to_many %v.%v needs a join_table", m.Type.Name(), sf.Name))
+		}
+		if rel.JoinKeys[0] == "" {
+			rel.JoinKeys = [2]string{m.Type.Name() + "Id", rel.Elem.Name() + "Id"}
+		}
+	}
+}
+
+// Keys returns the field of the schema and the field of the related schema holding the same value:
+// the foreign key and the referenced field of a belongs_to, has_one or has_many, or the fields
+// stored in the join table of a many_to_many.
+func (rel *RelationMeta) Keys() (owner *FieldMeta, related *FieldMeta, err error) {
+	if rel.Assoc == 0 {
+		return nil, nil, fmt.Errorf("changeset: %v isn't a declared association", rel.Name)
+	}
+	relatedMeta := MetaOf(rel.Elem)
+	ownerKey, relatedKey := rel.References, rel.ForeignKey
+	ownerMeta, refMeta := rel.owner, rel.owner
+	switch rel.Assoc {
+	case BelongsTo:
+		ownerKey, relatedKey = rel.ForeignKey, rel.References
+		refMeta = relatedMeta
+	case ManyToMany:
+		relatedKey = ""
+	}
+	if owner = ownerMeta.lookup(ownerKey, refMeta == ownerMeta); owner == nil {
+		return nil, nil, fmt.Errorf("changeset: %v has no field %v for %v", ownerMeta.Type.Name(), ownerKey, rel.Name)
+	}
+	if related = relatedMeta.lookup(relatedKey, refMeta == relatedMeta || rel.Assoc == ManyToMany); related == nil {
+		return nil, nil, fmt.Errorf("changeset: %v has no field %v for %v", relatedMeta.Type.Name(), relatedKey, rel.Name)
+	}
+	return owner, related, nil
+}
+
+// lookup finds a field by name or column, an empty name is the primary key when pk is set.
+func (m *Meta) lookup(name string, pk bool) *FieldMeta {
+	if name == "" && pk {
+		return m.PK
+	}
+	if f, ok := m.fields[name]; ok {
+		return f
+	}
+	return m.columns[name]
+}
+
+// Assoc returns the declared association of the field name.
+func (m *Meta) Assoc(name string) (*RelationMeta, bool) {
+	rel, ok := m.Relations[name]
+	if !ok || rel.Assoc == 0 {
+		return nil, false
+	}
+	return rel, true
+}
+
+// castAssoc casts the params of a declared association into changesets of the related schema,
+// value is a map for a belongs_to or has_one and a list of maps for a has_many or many_to_many.
+func (cs *ChangeSet) castAssoc(rschema reflect.Value, rel *RelationMeta, value interface{}) {
+	params := []map[string]interface{}{}
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		params = append(params, v)
+	case []map[string]interface{}:
+		params = v
+	case []interface{}:
+		for _, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				cs.AddError(rel.Name, "cast", "is invalid", map[string]interface{}{"type": rel.Elem.Name()})
+				return
+			}
+			params = append(params, m)
+		}
+	default:
+		cs.AddError(rel.Name, "cast", "is invalid", map[string]interface{}{"type": rel.Elem.Name()})
+		return
+	}
+	if !rel.Many && len(params) > 1 {
+		cs.AddError(rel.Name, "cast", "is invalid", map[string]interface{}{"type": rel.Elem.Name()})
+		return
+	}
+	children := make([]*ChangeSet, len(params))
+	for i, p := range params {
+		children[i] = CastValues(reflect.New(rel.Elem).Interface(), p)
+		if len(children[i].Errors) > 0 {
+			cs.AddError(rel.Name, "assoc", "is invalid")
+		}
+	}
+	cs.putAssoc(rschema, rel, children)
+}
+
+// PutAssoc sets the changesets of the related schemas of a declared association, Save inserts them with cs.
+func (cs *ChangeSet) PutAssoc(name string, children ...*ChangeSet) *ChangeSet {
+	rel, ok := MetaOf(cs.ReflectSchema.Type()).Assoc(name)
+	if !ok {
+		cs.AddError(name, "assoc", "isn't an association")
+		return cs
+	}
+	if !rel.Many && len(children) > 1 {
+		cs.AddError(name, "assoc", "holds one value")
+		return cs
+	}
+	cs.putAssoc(cs.ReflectSchema, rel, children)
+	return cs
+}
+
+// putAssoc stores children and points the relation field of the schema to their schemas.
+func (cs *ChangeSet) putAssoc(rschema reflect.Value, rel *RelationMeta, children []*ChangeSet) {
+	if cs.Assocs == nil {
+		cs.Assocs = map[string][]*ChangeSet{}
+	}
+	cs.Assocs[rel.Name] = children
+	field := rschema.FieldByIndex(rel.Index)
+	if !field.CanSet() {
+		return
+	}
+	if !rel.Many {
+		field.Set(reflect.Zero(field.Type()))
+		if len(children) > 0 && children[0].ReflectSchema.CanAddr() {
+			field.Set(children[0].ReflectSchema.Addr())
+		}
+		return
+	}
+	items := reflect.MakeSlice(field.Type(), 0, len(children))
+	for _, child := range children {
+		item := child.ReflectSchema
+		if field.Type().Elem().Kind() == reflect.Ptr {
+			if !item.CanAddr() {
+				continue
+			}
+			item = item.Addr()
+		}
+		items = reflect.Append(items, item)
+	}
+	field.Set(items)
+}
+
+// PutChange sets field to value as if it was cast, Save uses it to fill the foreign keys of associations.
+func (cs *ChangeSet) PutChange(field string, value interface{}) *ChangeSet {
+	cs.castValues(cs.ReflectSchema, map[string]interface{}{field: value}, nil)
+	return cs
+}
@@ -0,0 +1,19 @@
+package changeset
+
+import "testing"
+
+func BenchmarkCastClass(b *testing.B) {
+	msg := &UserMsg{UserName: "john", UserEmail: "john@mail.com", UserAge: 30, UserRole: "admin"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CastClass(&User{}, msg)
+	}
+}
+
+func BenchmarkCastValues(b *testing.B) {
+	values := map[string]interface{}{"Name": "john", "Email": "john@mail.com", "Age": float64(30), "Role": "admin"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CastValues(&User{}, values)
+	}
+}
@@ -0,0 +1,263 @@
+package changeset
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// castValues assigns values to the fields of rschema converting them to the declared field types,
+// a value which can't be converted adds a "cast" error instead.
+// When permitted isn't empty only those fields are cast.
+func (cs *ChangeSet) castValues(rschema reflect.Value, values map[string]interface{}, permitted []string) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	meta := MetaOf(rschema.Type())
+	for _, key := range keys {
+		if rel, ok := assocFor(meta, key); ok {
+			if isPermitted(rel.Name, permitted) {
+				cs.castAssoc(rschema, rel, values[key])
+			}
+			continue
+		}
+		col, ok := cs.fieldFor(key)
+		if !ok || !isPermitted(col, permitted) {
+			continue
+		}
+		box := cs.Boxes[col]
+		if _, isRel := box.val.(Schema); isRel {
+			continue
+		}
+		fm, ok := meta.Field(col)
+		if !ok {
+			continue
+		}
+		f := rschema.FieldByIndex(fm.Index)
+		if !f.CanSet() {
+			continue
+		}
+		casted, err := castValue(values[key], f.Type())
+		if err != nil {
+			cs.AddError(col, "cast", "is invalid", map[string]interface{}{
+				"type":  f.Type().String(),
+				"cause": err.Error(),
+			})
+			continue
+		}
+		if casted.Kind() == reflect.String && !cs.validateSize(col, casted.String()) {
+			continue
+		}
+		if (box.ops&(1<<NotNullable)) != 0 && cs.NotNullFields.Has(box.id) {
+			if !casted.IsZero() || (box.ops&(1<<AI)) != 0 {
+				cs.NotNullFields.Remove(box.id)
+			}
+		}
+		box.Val(casted.Interface())
+		f.Set(casted)
+		if (box.ops & (1 << AI)) != 0 {
+			continue
+		}
+		if !casted.IsZero() && !cs.isCasted(col) {
+			cs.CastedBoxes = append(cs.CastedBoxes, col)
+		}
+	}
+}
+
+// fieldFor finds the box of a param key, keys of JSON bodies are matched without case.
+func (cs *ChangeSet) fieldFor(key string) (string, bool) {
+	if _, ok := cs.Boxes[key]; ok {
+		return key, true
+	}
+	for col := range cs.Boxes {
+		if strings.EqualFold(col, key) {
+			return col, true
+		}
+	}
+	return "", false
+}
+
+// assocFor finds the declared association of a param key, matched without case like the fields.
+func assocFor(meta *Meta, key string) (*RelationMeta, bool) {
+	if rel, ok := meta.Assoc(key); ok {
+		return rel, true
+	}
+	for name := range meta.Relations {
+		if strings.EqualFold(name, key) {
+			return meta.Assoc(name)
+		}
+	}
+	return nil, false
+}
+
+func (cs *ChangeSet) isCasted(col string) bool {
+	for _, casted := range cs.CastedBoxes {
+		if casted == col {
+			return true
+		}
+	}
+	return false
+}
+
+func isPermitted(col string, permitted []string) bool {
+	if len(permitted) == 0 {
+		return true
+	}
+	for _, p := range permitted {
+		if p == col {
+			return true
+		}
+	}
+	return false
+}
+
+// castValue converts an untyped value (from encoding/json, a form or a query string) into typ.
+func castValue(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		return reflect.Zero(typ), nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Type().AssignableTo(typ) {
+		return rv, nil
+	}
+	if typ.Kind() == reflect.Ptr {
+		elem, err := castValue(value, typ.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	}
+	if typ == timeType {
+		s, ok := value.(string)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("can't cast %T to time.Time", value)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(t), nil
+	}
+
+	out := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			out.SetString(v)
+		case json.Number:
+			out.SetString(v.String())
+		case []byte:
+			out.SetString(string(v))
+		default:
+			return reflect.Value{}, fmt.Errorf("can't cast %T to %v", value, typ)
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			out.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.SetBool(b)
+		default:
+			return reflect.Value{}, fmt.Errorf("can't cast %T to %v", value, typ)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := castInt(value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if out.OverflowInt(n) {
+			return reflect.Value{}, fmt.Errorf("%v overflows %v", n, typ)
+		}
+		out.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := castInt(value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if n < 0 || out.OverflowUint(uint64(n)) {
+			return reflect.Value{}, fmt.Errorf("%v overflows %v", n, typ)
+		}
+		out.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f, err := castFloat(value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if out.OverflowFloat(f) {
+			return reflect.Value{}, fmt.Errorf("%v overflows %v", f, typ)
+		}
+		out.SetFloat(f)
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		// JSON fields come as a json string or as the decoded map/slice of the body
+		var data []byte
+		if s, ok := value.(string); ok {
+			data = []byte(s)
+		} else {
+			var err error
+			if data, err = json.Marshal(value); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		if err := json.Unmarshal(data, out.Addr().Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("can't cast %T to %v", value, typ)
+	}
+	return out, nil
+}
+
+func castInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	case json.Number:
+		return v.Int64()
+	case float32, float64:
+		f := reflect.ValueOf(v).Float()
+		if f != math.Trunc(f) || f > math.MaxInt64 || f < math.MinInt64 {
+			return 0, fmt.Errorf("%v isn't an integer", f)
+		}
+		return int64(f), nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("%v overflows int64", rv.Uint())
+		}
+		return int64(rv.Uint()), nil
+	}
+	return 0, fmt.Errorf("can't cast %T to an integer", value)
+}
+
+func castFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	case json.Number:
+		return v.Float64()
+	}
+	if f, ok := toFloat(value); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("can't cast %T to a float", value)
+}
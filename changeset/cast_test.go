@@ -0,0 +1,58 @@
+package changeset
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCastValuesCoercion(t *testing.T) {
+	values := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{"name": "john", "Email": "john@mail.com", "Age": 21}`), &values); err != nil {
+		t.Fatal(err)
+	}
+	user := &User{}
+	cs := CastValues(user, values)
+	if !cs.Valid() {
+		t.Fatalf("unexpected errors %v", cs.TraverseErrors())
+	}
+	if user.Name != "john" || user.Email != "john@mail.com" || user.Age != 21 {
+		t.Fatalf("values not cast: %+v", user)
+	}
+	if len(cs.CastedBoxes) != 3 {
+		t.Fatalf("unexpected casted boxes %v", cs.CastedBoxes)
+	}
+}
+
+func TestCastValuesErrors(t *testing.T) {
+	user := &User{}
+	cs := CastValues(user, map[string]interface{}{
+		"Name": "john",
+		"Age":  "twenty",
+		"Role": 12.5,
+	})
+	got := cs.TraverseErrors(func(err *FieldError) string {
+		return err.Code
+	})
+	if len(got["Age"]) != 1 || got["Age"][0] != "cast" || len(got["Role"]) != 1 {
+		t.Fatalf("unexpected errors %v", got)
+	}
+	if user.Age != 0 {
+		t.Fatalf("failed cast must not assign the field")
+	}
+	cs = CastValues(&User{}, map[string]interface{}{"Age": -1})
+	if len(cs.ErrorsOn("Age")) != 1 {
+		t.Fatalf("negative number must not cast to uint32")
+	}
+}
+
+func TestCastValuesPermitted(t *testing.T) {
+	user := &User{}
+	CastValues(user, map[string]interface{}{
+		"Name": "john",
+		"Role": "admin",
+		"Age":  json.Number("30"),
+	}, "Name", "Age")
+	if user.Role != "" || user.Name != "john" || user.Age != 30 {
+		t.Fatalf("permitted fields not honored: %+v", user)
+	}
+}
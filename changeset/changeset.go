@@ -2,9 +2,10 @@ package changeset
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
-	"strings"
+	"sort"
 )
 
 var JsonFieldsOfSchemas = map[string]map[string]bool{}
@@ -25,6 +26,7 @@ const (
 	Nullable
 	NotNullable
 	JSONOp
+	PK
 )
 type Box struct {
 	id         uint32
@@ -33,6 +35,7 @@ type Box struct {
 	val        interface{}
 	UpdatedCol string
 	RelTbName string
+	ColName string
 }
 
 func (b *Box) GetOps() uint8 {
@@ -82,6 +85,12 @@ func (b *Box) Size(s int) *Box {
 	return b
 }
 
+// Col sets the column name when it differs from the field name.
+func (b *Box) Col(name string) *Box {
+	b.ColName = name
+	return b
+}
+
 func (b *Box) JSONField() *Box {
 	b.ops |= 1 << JSONOp
 	return b
@@ -95,152 +104,135 @@ type ChangeSet struct {
 	ActionRepo ActionRepo
 	ReflectSchema reflect.Value
 	Boxes         map[string]*Box
-	NotNullFields uint32
+	NotNullFields FieldSet
 	CastedBoxes []string
 	SubChangeSets map[string]*ChangeSet
+	// Assocs are the changesets of the declared associations, by relation field name.
+	Assocs map[string][]*ChangeSet
+	Errors []*FieldError
+	Params map[string]interface{}
+	Constraints []*Constraint
 }
 
 
-func CastClass(schema Schema, msg interface{}) *ChangeSet {
+// CastClass casts the fields of msg prefixed by the schema name, schema is a Schema or a struct with `ecto` tags.
+func CastClass(schema interface{}, msg interface{}) *ChangeSet {
 	rschema := reflect.Indirect(reflect.ValueOf(schema))
-	cs := &ChangeSet{
-		Boxes:         schema.Validators(),
-		NotNullFields: 0,
-		SubChangeSets: map[string]*ChangeSet{},
-	}
+	meta := MetaOf(rschema.Type())
+	cs := newChangeSet(meta)
+	cs.Params = map[string]interface{}{}
 
-	var id uint32
-	for col, box := range cs.Boxes {
-		if (box.ops & (1<<NotNullable)) != 0 {
-			cs.NotNullFields |= 1<<id
-			box.id = id
+	rmsg := reflect.Indirect(reflect.ValueOf(msg))
+	for _, mf := range meta.msgFields(rmsg.Type()) {
+		value := rmsg.Field(mf.Index)
+		cs.Params[mf.Param] = value.Interface()
+		if mf.Field == nil {
+			continue
 		}
-		if box.ops & (1<<JSONOp) != 0 {
-			if _, ok := JsonFieldsOfSchemas[rschema.Type().Name()]; !ok {
-				JsonFieldsOfSchemas[rschema.Type().Name()] = make(map[string]bool)
+		col := mf.Param
+		box := cs.Boxes[col]
+		if (box.ops&(1<<NotNullable)) != 0 && cs.NotNullFields.Has(box.id) {
+			if !value.IsZero() || (box.ops&(1<<AI)) != 0 {
+				cs.NotNullFields.Remove(box.id)
 			}
-			JsonFieldsOfSchemas[rschema.Type().Name()][col] = true
 		}
-		id++
-	}
-
-	rmsg := reflect.Indirect(reflect.ValueOf(msg))
-	for i := 0; i < rmsg.NumField(); i++ {
-		str := strings.Split(rmsg.Type().Field(i).Name, rschema.Type().Name())
-		if strings.HasPrefix(rmsg.Type().Field(i).Name, rschema.Type().Name()) {
-			if _, ok := cs.Boxes[str[1]]; ok {
-				if (cs.NotNullFields & (1 << cs.Boxes[str[1]].id)) != 0 {
-					if !rmsg.Field(i).IsZero() || (cs.Boxes[str[1]].ops&(1<<AI)) != 0 {
-						cs.NotNullFields &= ^(1 << cs.Boxes[str[1]].id)
-					}
-				}
-				if rmsg.Field(i).Type().Kind() == reflect.String {
-					if len(rmsg.Field(i).Interface().(string)) <= cs.Boxes[str[1]].size {
-						cs.Boxes[str[1]].Val(rmsg.Field(i).Interface())
-					}
-				} else {
-					if _, can := cs.Boxes[str[1]].val.(Schema); can {
-						// this is relation class embedded
-						sub_cs := CastClass(cs.Boxes[str[1]].val.(Schema), rmsg.Field(i).Interface())
-						cs.SubChangeSets[str[1]] = sub_cs
-					} else {
-						cs.Boxes[str[1]].Val(rmsg.Field(i).Interface())
-					}
-				}
-
-				if cs.CastedBoxes == nil {
-					cs.CastedBoxes = []string{}
-				}
-
-				if _, can := cs.Boxes[str[1]].val.(Schema); can {
-					if rschema.FieldByName(str[1]).Type().Kind() == reflect.Slice {
-						rschema.FieldByName(str[1]).Set(reflect.Append(rschema.FieldByName(str[1]), reflect.ValueOf(cs.Boxes[str[1]].val)))
-					} else {
-						rschema.FieldByName(str[1]).Set(reflect.ValueOf(cs.Boxes[str[1]].val))
-					}
-				} else {
-					rschema.FieldByName(str[1]).Set(rmsg.Field(i))
-				}
-				if (cs.Boxes[str[1]].ops & (1 << AI)) != 0 {
-					continue
-				}
+		_, isRel := box.val.(Schema)
+		if value.Kind() == reflect.String {
+			if cs.validateSize(col, value.String()) {
+				box.Val(value.Interface())
+			}
+		} else if isRel {
+			// this is relation class embedded
+			cs.SubChangeSets[col] = CastClass(box.val, value.Interface())
+		} else {
+			box.Val(value.Interface())
+		}
 
-				if !rmsg.Field(i).IsZero()  {
-					if _, can := cs.Boxes[str[1]].val.(Schema); can {
-						if cs.Boxes[str[1]].UpdatedCol != "" {
-							cs.CastedBoxes = append(cs.CastedBoxes, str[1])
-						}
-					} else {
-						cs.CastedBoxes = append(cs.CastedBoxes, str[1])
-					}
-				}
+		field := rschema.FieldByIndex(mf.Field.Index)
+		if isRel {
+			if field.Kind() == reflect.Slice {
+				field.Set(reflect.Append(field, reflect.ValueOf(box.val)))
+			} else {
+				field.Set(reflect.ValueOf(box.val))
 			}
+		} else {
+			field.Set(value)
+		}
+		if (box.ops & (1 << AI)) != 0 {
+			continue
+		}
+
+		if !value.IsZero() && (!isRel || box.UpdatedCol != "") {
+			cs.CastedBoxes = append(cs.CastedBoxes, col)
 		}
 	}
 	cs.ReflectSchema = rschema
 	return cs
 }
 
-func CastValues(schema Schema, values map[string]interface{}) *ChangeSet {
+// Change returns a changeset of schema without casting anything, for deletes or to build changes by hand.
+func Change(schema interface{}) *ChangeSet {
 	rschema := reflect.Indirect(reflect.ValueOf(schema))
+	cs := newChangeSet(MetaOf(rschema.Type()))
+	cs.Params = map[string]interface{}{}
+	cs.ReflectSchema = rschema
+	return cs
+}
+
+// newChangeSet copies the boxes of the schema and marks its NotNullable fields as missing.
+func newChangeSet(meta *Meta) *ChangeSet {
 	cs := &ChangeSet{
-		Boxes:         schema.Validators(),
-		NotNullFields: 0,
+		Boxes:         meta.Boxes(),
+		CastedBoxes:   []string{},
 		SubChangeSets: map[string]*ChangeSet{},
 	}
-	var id uint32
 	for _, box := range cs.Boxes {
-		if box.ops & (1<<NotNullable) != 0 {
-			cs.NotNullFields |= 1<<id
-			box.id = id
+		if (box.ops & (1 << NotNullable)) != 0 {
+			cs.NotNullFields.Add(box.id)
 		}
-		id++
 	}
-	for col, value := range values {
-		if _, ok := cs.Boxes[col]; ok {
-			if cs.NotNullFields & (1<<cs.Boxes[col].id) != 0 {
-				if value != "" || value != 0 || value != nil || (cs.Boxes[col].ops & (1<<AI)) != 0{
-					cs.NotNullFields &= ^(1<<cs.Boxes[col].id)
-				}
-			}
-
-			if reflect.TypeOf(value).Kind() == reflect.String {
-				if len(value.(string)) <= cs.Boxes[col].size {
-
-				}
-			} else {
-				cs.Boxes[col].Val(value)
-			}
-
-			if cs.Boxes[col].val != nil {
-				rschema.FieldByName(col).Set(reflect.ValueOf(value))
-			}
-
-			if (cs.Boxes[col].ops & (1 << AI)) != 0 {
-				continue
-			}
+	return cs
+}
 
-			if value != "" || value != 0 {
-				cs.CastedBoxes = append(cs.CastedBoxes, col)
-			}
-		}
-	}
+// CastValues casts untyped values (JSON bodies, form values, query strings) into the schema field types.
+// When permitted is given only those fields are cast, like the whitelist of Ecto's cast/3.
+func CastValues(schema interface{}, values map[string]interface{}, permitted ...string) *ChangeSet {
+	rschema := reflect.Indirect(reflect.ValueOf(schema))
+	cs := newChangeSet(MetaOf(rschema.Type()))
+	cs.Params = values
 	cs.ReflectSchema = rschema
+	cs.castValues(rschema, values, permitted)
 	return cs
 }
 
+// Column returns the column written for field, relation boxes write <RelTbName><UpdatedCol>.
+func (cs *ChangeSet) Column(field string) string {
+	box, ok := cs.Boxes[field]
+	if !ok {
+		return field
+	}
+	if box.UpdatedCol != "" {
+		return box.RelTbName + box.UpdatedCol
+	}
+	if box.ColName != "" {
+		return box.ColName
+	}
+	return field
+}
+
 func (cs *ChangeSet) ValidInsert() bool {
-	return cs.NotNullFields == 0
+	return cs.NotNullFields.Empty()
 }
 
 func (cs *ChangeSet) NotNullErrors() error {
 	errs := fmt.Sprintf("Required Fields aren't Nullable (")
 	errFields := []string{}
 	for col, box := range cs.Boxes {
-		if (cs.NotNullFields & (1 << box.id))!= 0 {
+		if (box.ops&(1<<NotNullable)) != 0 && cs.NotNullFields.Has(box.id) {
 			errFields = append(errFields, col)
 		}
 	}
+	sort.Strings(errFields)
 
 	for i, errField := range errFields {
 		errs += errField
@@ -249,20 +241,7 @@ func (cs *ChangeSet) NotNullErrors() error {
 		}
 	}
 	errs += ")"
-	return fmt.Errorf(errs)
-}
-
-func (cs *ChangeSet) Unique(nameFields ...string) {
-	tb := strings.ToLower(cs.ReflectSchema.Type().Name())+ "s"
-	where := "WHERE "
-	args := []interface{}{}
-	for i, nameField := range nameFields {
-		where += fmt.Sprintf("`%v`.`%v` = ?", tb, nameField)
-		args = append(args, cs.Boxes[nameField].val)
-		if i < len(nameFields) {
-			where += " AND"
-		}
-	}
+	return errors.New(errs)
 }
 
 func (cs *ChangeSet) SetRelValues(values map[string]interface{}) *ChangeSet {
@@ -275,35 +254,7 @@ func (cs *ChangeSet) SetRelValues(values map[string]interface{}) *ChangeSet {
 	return cs
 }
 
-func (cs *ChangeSet) AppendCastValue(interfaceSchema interface{}, values map[string]interface{}) {
+func (cs *ChangeSet) AppendCastValue(interfaceSchema interface{}, values map[string]interface{}, permitted ...string) {
 	rschema := reflect.Indirect(reflect.ValueOf(interfaceSchema))
-	for col, value := range values {
-		if _, ok := cs.Boxes[col]; ok {
-			if cs.NotNullFields & (1<<cs.Boxes[col].id) != 0 {
-				if value != "" || value != 0 || value != nil || (cs.Boxes[col].ops & (1<<AI)) != 0{
-					cs.NotNullFields &= ^(1<<cs.Boxes[col].id)
-				}
-			}
-
-			if reflect.TypeOf(value).Kind() == reflect.String {
-				if len(value.(string)) <= cs.Boxes[col].size {
-
-				}
-			} else {
-				cs.Boxes[col].Val(value)
-			}
-
-			if cs.Boxes[col].val != nil {
-				rschema.FieldByName(col).Set(reflect.ValueOf(value))
-			}
-
-			if (cs.Boxes[col].ops & (1 << AI)) != 0 {
-				continue
-			}
-
-			if value != "" || value != 0 {
-				cs.CastedBoxes = append(cs.CastedBoxes, col)
-			}
-		}
-	}
+	cs.castValues(rschema, values, permitted)
 }
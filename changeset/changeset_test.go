@@ -0,0 +1,29 @@
+package changeset
+
+import "testing"
+
+type Member struct {
+	Id    uint32
+	Email string
+}
+
+func (m *Member) Validators() map[string]*Box {
+	return map[string]*Box{
+		"Id":    NewBox().Ops(AI),
+		"Email": NewBox().Ops(NotNullable).Size(64),
+	}
+}
+
+func TestCastValuesRequired(t *testing.T) {
+	cs := CastValues(&Member{}, map[string]interface{}{"Email": ""})
+	if cs.ValidInsert() || len(cs.CastedBoxes) != 0 {
+		t.Fatalf("an empty value must leave the field required and uncast, got %v", cs.CastedBoxes)
+	}
+	if err := cs.NotNullErrors(); err.Error() != "Required Fields aren't Nullable (Email)" {
+		t.Fatalf("unexpected error %q", err)
+	}
+	cs = CastValues(&Member{}, map[string]interface{}{"Email": "ann@mail.com"})
+	if !cs.ValidInsert() || len(cs.CastedBoxes) != 1 {
+		t.Fatalf("a value must fill the required field, got %v", cs.CastedBoxes)
+	}
+}
@@ -0,0 +1,163 @@
+package changeset
+
+import (
+	"strings"
+)
+
+type ConstraintKind uint8
+
+const (
+	ConstraintUnique ConstraintKind = iota + 1
+	ConstraintForeignKey
+	ConstraintNoAssoc
+	ConstraintCheck
+	ConstraintNotNull
+)
+
+// Constraint maps a database constraint violation to an error on Field.
+// Name is the constraint or index name in the database, Fields are the constrained columns.
+type Constraint struct {
+	Kind    ConstraintKind
+	Name    string
+	Field   string
+	Fields  []string
+	Message string
+}
+
+func (c *Constraint) Named(name string) *Constraint {
+	c.Name = name
+	return c
+}
+
+func (c *Constraint) WithMessage(message string) *Constraint {
+	c.Message = message
+	return c
+}
+
+// TableName is the table of the schema of the changeset, see TableOf.
+func (cs *ChangeSet) TableName() string {
+	return tableName(cs.ReflectSchema.Type())
+}
+
+// UniqueConstraint declares a unique index on fields, the error goes on the first field.
+// The default name is <table>_<fields>_index, use Named when the index is named differently.
+func (cs *ChangeSet) UniqueConstraint(field string, more ...string) *Constraint {
+	fields := append([]string{field}, more...)
+	c := &Constraint{
+		Kind:    ConstraintUnique,
+		Name:    cs.TableName() + "_" + strings.ToLower(strings.Join(fields, "_")) + "_index",
+		Field:   field,
+		Fields:  fields,
+		Message: "has already been taken",
+	}
+	cs.Constraints = append(cs.Constraints, c)
+	return c
+}
+
+// ForeignKeyConstraint declares that field references another table, the error is added when the row doesn't exist.
+// The default name is <table>_<field>_fkey.
+func (cs *ChangeSet) ForeignKeyConstraint(field string) *Constraint {
+	c := &Constraint{
+		Kind:    ConstraintForeignKey,
+		Name:    cs.TableName() + "_" + strings.ToLower(field) + "_fkey",
+		Field:   field,
+		Fields:  []string{field},
+		Message: "does not exist",
+	}
+	cs.Constraints = append(cs.Constraints, c)
+	return c
+}
+
+// NoAssocConstraint declares that rows of the relation field still reference this one, it's checked on delete.
+// The default name is the foreign key of the related table, <field>_<schema>id_fkey ("posts_userid_fkey").
+func (cs *ChangeSet) NoAssocConstraint(field string) *Constraint {
+	c := &Constraint{
+		Kind:    ConstraintNoAssoc,
+		Name:    strings.ToLower(field) + "_" + strings.ToLower(cs.ReflectSchema.Type().Name()) + "id_fkey",
+		Field:   field,
+		Fields:  []string{field},
+		Message: "is still associated with this entry",
+	}
+	cs.Constraints = append(cs.Constraints, c)
+	return c
+}
+
+// CheckConstraint declares a check constraint, it has no default name.
+func (cs *ChangeSet) CheckConstraint(field string, name string) *Constraint {
+	c := &Constraint{
+		Kind:    ConstraintCheck,
+		Name:    name,
+		Field:   field,
+		Fields:  []string{field},
+		Message: "is invalid",
+	}
+	cs.Constraints = append(cs.Constraints, c)
+	return c
+}
+
+// Unique is kept for the old call sites, it declares a UniqueConstraint on nameFields.
+func (cs *ChangeSet) Unique(nameFields ...string) {
+	if len(nameFields) == 0 {
+		return
+	}
+	cs.UniqueConstraint(nameFields[0], nameFields[1:]...)
+}
+
+// ConstraintError adds the error of the declared constraint matching the violation.
+// The violation matches by name or, when the driver doesn't report names, by columns,
+// a violation without name nor columns matches the first constraint of its kind.
+// NOT NULL violations need no declaration, they are added on the column when it's a field of the schema.
+// It returns false when no constraint was declared for it.
+func (cs *ChangeSet) ConstraintError(kind ConstraintKind, name string, columns []string) bool {
+	if kind == ConstraintNotNull {
+		if len(columns) == 0 {
+			return false
+		}
+		if _, ok := cs.Boxes[columns[0]]; !ok {
+			return false
+		}
+		cs.AddError(columns[0], kind.code(), "can't be blank", map[string]interface{}{
+			"constraint": kind.code(),
+		})
+		return true
+	}
+	for _, c := range cs.Constraints {
+		if c.Kind != kind {
+			continue
+		}
+		if (name != "" && c.Name == name) || (name == "" && sameFields(c.Fields, columns)) || (name == "" && len(columns) == 0) {
+			cs.AddError(c.Field, kind.code(), c.Message, map[string]interface{}{
+				"constraint":      kind.code(),
+				"constraint_name": c.Name,
+			})
+			return true
+		}
+	}
+	return false
+}
+
+func (k ConstraintKind) code() string {
+	switch k {
+	case ConstraintForeignKey:
+		return "foreign"
+	case ConstraintNoAssoc:
+		return "no_assoc"
+	case ConstraintCheck:
+		return "check"
+	case ConstraintNotNull:
+		return "required"
+	}
+	return "unique"
+}
+
+func sameFields(fields []string, columns []string) bool {
+	if len(fields) == 0 || len(fields) != len(columns) {
+		return false
+	}
+	for i := range fields {
+		if !strings.EqualFold(fields[i], columns[i]) {
+			return false
+		}
+	}
+	return true
+}
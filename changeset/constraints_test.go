@@ -0,0 +1,15 @@
+package changeset
+
+import "testing"
+
+func TestUniqueConstraint(t *testing.T) {
+	cs := Change(&Person{})
+	c := cs.UniqueConstraint("Email", "Id")
+	if c.Field != "Email" || len(c.Fields) != 2 || c.Name != "people_email_id_index" {
+		t.Fatalf("unexpected constraint %+v", c)
+	}
+	cs.Unique()
+	if len(cs.Constraints) != 1 {
+		t.Fatalf("Unique without fields must not declare a constraint")
+	}
+}
@@ -0,0 +1,120 @@
+package changeset
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrInvalid = errors.New("changeset is invalid")
+
+// FieldError is one validation error of a field, Message can reference Meta values as %{key}.
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+	Meta    map[string]interface{}
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Text()
+}
+
+// Text returns the message with the %{key} references replaced by the Meta values.
+func (e *FieldError) Text() string {
+	msg := e.Message
+	for k, v := range e.Meta {
+		msg = strings.ReplaceAll(msg, "%{"+k+"}", fmt.Sprint(v))
+	}
+	return msg
+}
+
+// ValidationError is returned by the Repo when the changeset isn't valid, the errors stay on ChangeSet.
+type ValidationError struct {
+	ChangeSet *ChangeSet
+}
+
+func (e *ValidationError) Error() string {
+	msgs := []string{}
+	for _, err := range e.ChangeSet.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return ErrInvalid.Error() + ": " + strings.Join(msgs, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalid
+}
+
+// AddError appends an error on field, the first meta map is kept as metadata of the error.
+func (cs *ChangeSet) AddError(field string, code string, message string, meta ...map[string]interface{}) *ChangeSet {
+	err := &FieldError{
+		Field:   field,
+		Code:    code,
+		Message: message,
+		Meta:    map[string]interface{}{},
+	}
+	if len(meta) > 0 && meta[0] != nil {
+		err.Meta = meta[0]
+	}
+	cs.Errors = append(cs.Errors, err)
+	return cs
+}
+
+// ErrorsOn returns the errors of one field.
+func (cs *ChangeSet) ErrorsOn(field string) []*FieldError {
+	errs := []*FieldError{}
+	for _, err := range cs.Errors {
+		if err.Field == field {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (cs *ChangeSet) hasError(field string, code string) bool {
+	for _, err := range cs.Errors {
+		if err.Field == field && err.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid reports whether no error was added and no required field is missing.
+func (cs *ChangeSet) Valid() bool {
+	return len(cs.Errors) == 0 && cs.ValidInsert()
+}
+
+// ValidateRequired turns every missing NotNullable field into a "required" error.
+func (cs *ChangeSet) ValidateRequired() *ChangeSet {
+	fields := []string{}
+	for col, box := range cs.Boxes {
+		if (box.ops&(1<<NotNullable)) != 0 && cs.NotNullFields.Has(box.id) {
+			fields = append(fields, col)
+		}
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if !cs.hasError(field, "required") {
+			cs.AddError(field, "required", "can't be blank")
+		}
+	}
+	return cs
+}
+
+// TraverseErrors groups the messages by field, msgFunc can replace the default %{key} interpolation.
+func (cs *ChangeSet) TraverseErrors(msgFunc ...func(err *FieldError) string) map[string][]string {
+	result := map[string][]string{}
+	for _, err := range cs.Errors {
+		msg := ""
+		if len(msgFunc) > 0 && msgFunc[0] != nil {
+			msg = msgFunc[0](err)
+		} else {
+			msg = err.Text()
+		}
+		result[err.Field] = append(result[err.Field], msg)
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package changeset
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type User struct {
+	Id       uint32
+	Name     string
+	Email    string
+	Age      uint32
+	Role     string
+	Password string
+}
+
+func (u *User) Validators() map[string]*Box {
+	return map[string]*Box{
+		"Id":       NewBox().Ops(AI),
+		"Name":     NewBox().Ops(NotNullable).Size(16),
+		"Email":    NewBox().Ops(NotNullable).Size(64),
+		"Age":      NewBox(),
+		"Role":     NewBox().Size(16),
+		"Password": NewBox().Size(64),
+	}
+}
+
+type UserMsg struct {
+	UserName                 string
+	UserEmail                string
+	UserAge                  uint32
+	UserRole                 string
+	UserPassword             string
+	UserPasswordConfirmation string
+}
+
+func TestValidateRequired(t *testing.T) {
+	cs := CastClass(&User{}, &UserMsg{UserAge: 20})
+	if cs.Valid() {
+		t.Fatalf("changeset without Name and Email must be invalid")
+	}
+	cs.ValidateRequired().ValidateRequired()
+	want := map[string][]string{
+		"Email": {"can't be blank"},
+		"Name":  {"can't be blank"},
+	}
+	if got := cs.TraverseErrors(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	err := error(&ValidationError{ChangeSet: cs})
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("ValidationError must wrap ErrInvalid")
+	}
+}
+
+func TestTraverseErrors(t *testing.T) {
+	cs := CastClass(&User{}, &UserMsg{UserName: "john", UserEmail: "john@mail.com"})
+	if !cs.Valid() {
+		t.Fatalf("unexpected errors %v", cs.Errors)
+	}
+	cs.AddError("Name", "length", "should be at least %{count} character(s)", map[string]interface{}{"count": 5})
+	if got := cs.TraverseErrors()["Name"]; len(got) != 1 || got[0] != "should be at least 5 character(s)" {
+		t.Fatalf("unexpected message %v", got)
+	}
+	got := cs.TraverseErrors(func(err *FieldError) string {
+		return err.Code
+	})
+	if !reflect.DeepEqual(got, map[string][]string{"Name": {"length"}}) {
+		t.Fatalf("unexpected traversal %v", got)
+	}
+}
@@ -0,0 +1,32 @@
+package changeset
+
+// FieldSet is a bitset of box ids, it grows with the number of fields so wide schemas aren't limited.
+type FieldSet []uint64
+
+func (s *FieldSet) Add(id uint32) {
+	word := int(id / 64)
+	for len(*s) <= word {
+		*s = append(*s, 0)
+	}
+	(*s)[word] |= 1 << (id % 64)
+}
+
+func (s FieldSet) Remove(id uint32) {
+	if word := int(id / 64); word < len(s) {
+		s[word] &= ^(1 << (id % 64))
+	}
+}
+
+func (s FieldSet) Has(id uint32) bool {
+	word := int(id / 64)
+	return word < len(s) && s[word]&(1<<(id%64)) != 0
+}
+
+func (s FieldSet) Empty() bool {
+	for _, word := range s {
+		if word != 0 {
+			return false
+		}
+	}
+	return true
+}
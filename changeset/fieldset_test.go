@@ -0,0 +1,51 @@
+package changeset
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWideSchemaRequiredFields(t *testing.T) {
+	fields := []reflect.StructField{}
+	for i := 0; i < 70; i++ {
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("F%02d", i),
+			Type: reflect.TypeOf(""),
+			Tag:  `ecto:"notnull"`,
+		})
+	}
+	wide := reflect.StructOf(fields)
+	values := map[string]interface{}{}
+	for i := 0; i < 70; i++ {
+		if i != 65 && i != 3 {
+			values[fmt.Sprintf("F%02d", i)] = "x"
+		}
+	}
+	cs := CastValues(reflect.New(wide).Interface(), values)
+	if cs.ValidInsert() {
+		t.Fatalf("F03 and F65 are missing")
+	}
+	if err := cs.NotNullErrors(); err.Error() != "Required Fields aren't Nullable (F03, F65)" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cs.AppendCastValue(cs.ReflectSchema.Addr().Interface(), map[string]interface{}{"F03": "x", "F65": "x"})
+	if !cs.ValidInsert() || !cs.Valid() {
+		t.Fatalf("every required field is cast: %v", cs.NotNullErrors())
+	}
+}
+
+func TestFieldSet(t *testing.T) {
+	var s FieldSet
+	s.Add(3)
+	s.Add(130)
+	if !s.Has(3) || !s.Has(130) || s.Has(64) || s.Empty() {
+		t.Fatalf("unexpected set %v", s)
+	}
+	s.Remove(3)
+	s.Remove(130)
+	s.Remove(500)
+	if !s.Empty() {
+		t.Fatalf("set must be empty")
+	}
+}
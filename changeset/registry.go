@@ -0,0 +1,205 @@
+package changeset
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FieldMeta describes a column field of a schema.
+type FieldMeta struct {
+	Id     uint32
+	Name   string
+	Column string
+	Index  []int
+	Type   reflect.Type
+	Ops    uint8
+	Size   int
+	JSON   bool
+}
+
+// RelationMeta describes a struct field holding related schemas, a pointer or a slice of them.
+// The association fields are set when the field declares one with its `ecto` tag.
+type RelationMeta struct {
+	Name       string
+	Index      []int
+	Elem       reflect.Type
+	Many       bool
+	Assoc      AssocKind
+	ForeignKey string
+	References string
+	JoinTable  string
+	JoinKeys   [2]string
+	owner      *Meta
+}
+
+// Meta is computed once per schema type so casting and scanning don't repeat the reflection work.
+type Meta struct {
+	Type      reflect.Type
+	Table     string
+	Fields    []*FieldMeta
+	Relations map[string]*RelationMeta
+	Id        *FieldMeta
+	PK        *FieldMeta
+	fields    map[string]*FieldMeta
+	columns   map[string]*FieldMeta
+	boxes     map[string]*Box
+	msgs      sync.Map
+}
+
+var (
+	metas    sync.Map
+	jsonLock sync.Mutex
+)
+
+// MetaOf returns the cached metadata of a schema value or type.
+func MetaOf(schema interface{}) *Meta {
+	t, ok := schema.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(schema)
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if m, ok := metas.Load(t); ok {
+		return m.(*Meta)
+	}
+	m, _ := metas.LoadOrStore(t, newMeta(t))
+	return m.(*Meta)
+}
+
+func newMeta(t reflect.Type) *Meta {
+	m := &Meta{
+		Type:      t,
+		Table:     tableName(t),
+		Relations: map[string]*RelationMeta{},
+		fields:    map[string]*FieldMeta{},
+		columns:   map[string]*FieldMeta{},
+		boxes:     Validators(reflect.New(t).Interface()),
+	}
+	names := make([]string, 0, len(m.boxes))
+	for name := range m.boxes {
+		names = append(names, name)
+	}
+	// ids follow the field names so they are the same on every run
+	sort.Strings(names)
+	for id, name := range names {
+		box := m.boxes[name]
+		box.id = uint32(id)
+		sf, ok := t.FieldByName(name)
+		if !ok {
+			continue
+		}
+		f := &FieldMeta{
+			Id:     uint32(id),
+			Name:   name,
+			Column: name,
+			Index:  sf.Index,
+			Type:   sf.Type,
+			Ops:    box.ops,
+			Size:   box.size,
+			JSON:   (box.ops & (1 << JSONOp)) != 0,
+		}
+		if box.ColName != "" {
+			f.Column = box.ColName
+		}
+		m.Fields = append(m.Fields, f)
+		m.fields[name] = f
+		m.columns[f.Column] = f
+		if f.JSON {
+			jsonLock.Lock()
+			if _, ok := JsonFieldsOfSchemas[t.Name()]; !ok {
+				JsonFieldsOfSchemas[t.Name()] = make(map[string]bool)
+			}
+			JsonFieldsOfSchemas[t.Name()][name] = true
+			jsonLock.Unlock()
+		}
+	}
+	m.Id = m.fields["Id"]
+	m.PK = m.Id
+	for _, f := range m.Fields {
+		if (f.Ops & (1 << PK)) != 0 {
+			m.PK = f
+			break
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || !isRelationType(sf.Type) {
+			continue
+		}
+		if f, ok := m.fields[sf.Name]; ok && f.JSON {
+			continue
+		}
+		rel := &RelationMeta{Name: sf.Name, Index: sf.Index, Elem: sf.Type}
+		if rel.Elem.Kind() == reflect.Slice {
+			rel.Many = true
+			rel.Elem = rel.Elem.Elem()
+		}
+		for rel.Elem.Kind() == reflect.Ptr {
+			rel.Elem = rel.Elem.Elem()
+		}
+		m.parseAssoc(sf, rel)
+		m.Relations[sf.Name] = rel
+	}
+	return m
+}
+
+func (m *Meta) Field(name string) (*FieldMeta, bool) {
+	f, ok := m.fields[name]
+	return f, ok
+}
+
+// FieldByColumn returns the field stored in column, a column without declared name is the field name.
+func (m *Meta) FieldByColumn(column string) (*FieldMeta, bool) {
+	f, ok := m.columns[column]
+	return f, ok
+}
+
+// Boxes returns copies of the boxes of the schema, each changeset owns its boxes.
+func (m *Meta) Boxes() map[string]*Box {
+	boxes := make(map[string]*Box, len(m.boxes))
+	for name, tpl := range m.boxes {
+		box := *tpl
+		if _, isRel := tpl.val.(Schema); isRel {
+			rv := reflect.ValueOf(tpl.val)
+			if rv.Kind() == reflect.Ptr {
+				clone := reflect.New(rv.Elem().Type())
+				clone.Elem().Set(rv.Elem())
+				box.val = clone.Interface()
+			}
+		}
+		boxes[name] = &box
+	}
+	return boxes
+}
+
+// msgField is a field of a message cast by CastClass, Param is the field name without the schema prefix.
+type msgField struct {
+	Index int
+	Param string
+	Field *FieldMeta
+}
+
+// msgFields returns the fields of msg prefixed by the schema name, computed once per message type.
+func (m *Meta) msgFields(msg reflect.Type) []*msgField {
+	if fields, ok := m.msgs.Load(msg); ok {
+		return fields.([]*msgField)
+	}
+	fields := []*msgField{}
+	for i := 0; i < msg.NumField(); i++ {
+		name := msg.Field(i).Name
+		if !strings.HasPrefix(name, m.Type.Name()) {
+			continue
+		}
+		param := strings.TrimPrefix(name, m.Type.Name())
+		f := &msgField{Index: i, Param: param}
+		if _, ok := m.boxes[param]; ok {
+			f.Field = m.fields[param]
+		}
+		fields = append(fields, f)
+	}
+	actual, _ := m.msgs.LoadOrStore(msg, fields)
+	return actual.([]*msgField)
+}
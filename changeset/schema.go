@@ -0,0 +1,134 @@
+package changeset
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tabler lets a schema choose its table name instead of the lowercase type name plus "s".
+type Tabler interface {
+	TableName() string
+}
+
+// TableOf returns the table name of a schema value or type.
+func TableOf(schema interface{}) string {
+	if t, ok := schema.(reflect.Type); ok {
+		return tableName(t)
+	}
+	return tableName(reflect.Indirect(reflect.ValueOf(schema)).Type())
+}
+
+var tablerType = reflect.TypeOf((*Tabler)(nil)).Elem()
+
+func tableName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(tablerType) {
+		return reflect.New(t).Interface().(Tabler).TableName()
+	}
+	return strings.ToLower(t.Name()) + "s"
+}
+
+// FieldOfColumn returns the field of the schema type stored in column, when their names differ.
+func FieldOfColumn(t reflect.Type, column string) (string, bool) {
+	f, ok := MetaOf(t).FieldByColumn(column)
+	if !ok {
+		return "", false
+	}
+	return f.Name, true
+}
+
+// tagField is a field declared by an `ecto:"..."` struct tag.
+type tagField struct {
+	name   string
+	column string
+	size   int
+	ops    []FieldOp
+}
+
+var tagSchemas sync.Map
+
+// Validators returns the boxes of a schema: the Validators() map of a Schema,
+// or the boxes declared with `ecto:"column:user_email,size:255,notnull,json,pk,autoincrement"` tags.
+// Every exported field of a tagged struct is a column, `ecto:"-"` skips one.
+func Validators(schema interface{}) map[string]*Box {
+	if s, ok := schema.(Schema); ok {
+		return s.Validators()
+	}
+	t := reflect.Indirect(reflect.ValueOf(schema)).Type()
+	fields, ok := tagSchemas.Load(t)
+	if !ok {
+		fields, _ = tagSchemas.LoadOrStore(t, parseTags(t))
+	}
+	boxes := map[string]*Box{}
+	for _, f := range fields.([]*tagField) {
+		box := NewBox().Ops(f.ops...).Size(f.size)
+		box.ColName = f.column
+		boxes[f.name] = box
+	}
+	return boxes
+}
+
+func parseTags(t reflect.Type) []*tagField {
+	fields := []*tagField{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("ecto")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		f := &tagField{name: sf.Name}
+		assoc := false
+		for _, opt := range strings.Split(tag, ",") {
+			key, value := opt, ""
+			if i := strings.Index(opt, ":"); i >= 0 {
+				key, value = opt[:i], opt[i+1:]
+			}
+			switch strings.TrimSpace(key) {
+			case "column":
+				f.column = value
+			case "size":
+				size, err := strconv.Atoi(value)
+				if err != nil {
+					panic(fmt.Sprintf("changeset: invalid size %q on %v.%v", value, t.Name(), sf.Name))
+				}
+				f.size = size
+			case "notnull":
+				f.ops = append(f.ops, NotNullable)
+			case "null":
+				f.ops = append(f.ops, Nullable)
+			case "json":
+				f.ops = append(f.ops, JSONOp)
+			case "pk":
+				f.ops = append(f.ops, PK)
+			case "autoincrement":
+				f.ops = append(f.ops, AI)
+			case "belongs_to", "has_one", "has_many", "many_to_many":
+				assoc = true
+			}
+		}
+		if assoc || (!hasTag && isRelationType(sf.Type)) {
+			// relations are declared by the schema, a plain struct field isn't a column
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func isRelationType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+	} else if t.Kind() != reflect.Ptr {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
+}
@@ -0,0 +1,106 @@
+package changeset
+
+import (
+	"reflect"
+	"testing"
+)
+
+type Person struct {
+	Id      uint32   `ecto:"pk,autoincrement"`
+	Email   string   `ecto:"column:user_email,size:255,notnull"`
+	Tags    []string `ecto:"json"`
+	Secret  string   `ecto:"-"`
+	Friends []*Person
+}
+
+func (p *Person) TableName() string {
+	return "people"
+}
+
+type UserProfile struct {
+	Id  uint32
+	Bio string
+}
+
+func TestTaggedSchema(t *testing.T) {
+	boxes := Validators(&Person{})
+	if len(boxes) != 3 {
+		t.Fatalf("unexpected boxes %v", boxes)
+	}
+	if boxes["Email"].ColName != "user_email" || boxes["Email"].size != 255 || boxes["Email"].ops&(1<<NotNullable) == 0 {
+		t.Fatalf("Email tag not parsed: %+v", boxes["Email"])
+	}
+	if boxes["Id"].ops != 1<<PK|1<<AI || boxes["Tags"].ops != 1<<JSONOp {
+		t.Fatalf("ops not parsed")
+	}
+	boxes["Email"].Size(1)
+	if Validators(&Person{})["Email"].size != 255 {
+		t.Fatalf("boxes must not be shared between calls")
+	}
+
+	person := &Person{}
+	cs := CastValues(person, map[string]interface{}{"Email": "a@b.c", "Tags": []interface{}{"x"}})
+	if !cs.Valid() || person.Email != "a@b.c" || !reflect.DeepEqual(person.Tags, []string{"x"}) {
+		t.Fatalf("tagged schema not cast: %+v %v", person, cs.TraverseErrors())
+	}
+	if cs.Column("Email") != "user_email" || cs.TableName() != "people" {
+		t.Fatalf("unexpected column %v or table %v", cs.Column("Email"), cs.TableName())
+	}
+	if field, ok := FieldOfColumn(reflect.TypeOf(Person{}), "user_email"); !ok || field != "Email" {
+		t.Fatalf("column not mapped to its field")
+	}
+}
+
+func TestTableOf(t *testing.T) {
+	if TableOf(&UserProfile{}) != "userprofiles" || TableOf(reflect.TypeOf(Person{})) != "people" {
+		t.Fatalf("unexpected table names")
+	}
+}
+
+type Tag struct {
+	Id   uint32 `ecto:"pk,autoincrement"`
+	Name string
+}
+
+type Article struct {
+	Id       uint32  `ecto:"pk,autoincrement"`
+	AuthorId uint32  `ecto:"column:author_id"`
+	Author   *Person `ecto:"belongs_to"`
+	Tags     []*Tag  `ecto:"many_to_many,join_table:articles_tags"`
+	Related  []*Article
+}
+
+func TestAssociations(t *testing.T) {
+	meta := MetaOf(&Article{})
+	if _, ok := meta.Field("Author"); ok {
+		t.Fatalf("an association isn't a column")
+	}
+	author, ok := meta.Assoc("Author")
+	if !ok || author.Assoc != BelongsTo || author.ForeignKey != "AuthorId" {
+		t.Fatalf("belongs_to not declared: %+v", author)
+	}
+	owner, related, err := author.Keys()
+	if err != nil || owner.Column != "author_id" || related.Name != "Id" {
+		t.Fatalf("unexpected keys %+v %+v %v", owner, related, err)
+	}
+	tags, _ := meta.Assoc("Tags")
+	if tags.JoinTable != "articles_tags" || tags.JoinKeys != [2]string{"ArticleId", "TagId"} {
+		t.Fatalf("many_to_many defaults not set: %+v", tags)
+	}
+	if _, ok := meta.Assoc("Related"); ok || meta.Relations["Related"] == nil {
+		t.Fatalf("an undeclared relation isn't an association")
+	}
+
+	article := &Article{}
+	cs := CastValues(article, map[string]interface{}{"tags": []interface{}{map[string]interface{}{"Name": "go"}}, "author": "ann"})
+	if len(cs.Assocs["Tags"]) != 1 || len(article.Tags) != 1 || article.Tags[0].Name != "go" {
+		t.Fatalf("tags not cast: %+v", cs.Assocs)
+	}
+	if len(cs.ErrorsOn("Author")) != 1 {
+		t.Fatalf("an invalid association must add an error: %v", cs.TraverseErrors())
+	}
+	cs.PutAssoc("Author", Change(&Person{Email: "a@b.c"}))
+	if article.Author == nil || article.Author.Email != "a@b.c" {
+		t.Fatalf("PutAssoc didn't set the relation")
+	}
+}
@@ -0,0 +1,199 @@
+package changeset
+
+import (
+	"reflect"
+	"regexp"
+	"unicode/utf8"
+)
+
+// validateSize reports a length error when the string is longer than Box.Size, a zero size has no limit.
+func (cs *ChangeSet) validateSize(field string, s string) bool {
+	size := cs.Boxes[field].size
+	if size <= 0 || utf8.RuneCountInString(s) <= size {
+		return true
+	}
+	cs.AddError(field, "length", "should be at most %{count} character(s)", map[string]interface{}{
+		"count": size,
+		"kind":  "max",
+	})
+	return false
+}
+
+// change returns the value cast for field, validators skip the fields which weren't changed.
+func (cs *ChangeSet) change(field string) (interface{}, bool) {
+	for _, col := range cs.CastedBoxes {
+		if col == field {
+			f := cs.ReflectSchema.FieldByName(field)
+			if !f.IsValid() {
+				return nil, false
+			}
+			return f.Interface(), true
+		}
+	}
+	return nil, false
+}
+
+// ValidateLength checks the length of a string or a slice, max <= 0 means no upper bound.
+func (cs *ChangeSet) ValidateLength(field string, min int, max int) *ChangeSet {
+	value, ok := cs.change(field)
+	if !ok {
+		return cs
+	}
+	var length int
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		length = utf8.RuneCountInString(rv.String())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		length = rv.Len()
+	default:
+		return cs
+	}
+	if length < min {
+		return cs.AddError(field, "length", "should be at least %{count} character(s)", map[string]interface{}{
+			"count": min,
+			"kind":  "min",
+		})
+	}
+	if max > 0 && length > max {
+		return cs.AddError(field, "length", "should be at most %{count} character(s)", map[string]interface{}{
+			"count": max,
+			"kind":  "max",
+		})
+	}
+	return cs
+}
+
+func (cs *ChangeSet) ValidateFormat(field string, format *regexp.Regexp) *ChangeSet {
+	value, ok := cs.change(field)
+	if !ok {
+		return cs
+	}
+	if s, isString := value.(string); isString && !format.MatchString(s) {
+		cs.AddError(field, "format", "has invalid format", map[string]interface{}{
+			"format": format.String(),
+		})
+	}
+	return cs
+}
+
+// ValidateNumber checks gte <= value <= lte, use math.Inf for an open bound.
+func (cs *ChangeSet) ValidateNumber(field string, gte float64, lte float64) *ChangeSet {
+	value, ok := cs.change(field)
+	if !ok {
+		return cs
+	}
+	n, isNumber := toFloat(value)
+	if !isNumber {
+		return cs
+	}
+	if n < gte {
+		return cs.AddError(field, "number", "must be greater than or equal to %{number}", map[string]interface{}{
+			"number": gte,
+			"kind":   "greater_than_or_equal_to",
+		})
+	}
+	if n > lte {
+		return cs.AddError(field, "number", "must be less than or equal to %{number}", map[string]interface{}{
+			"number": lte,
+			"kind":   "less_than_or_equal_to",
+		})
+	}
+	return cs
+}
+
+func (cs *ChangeSet) ValidateInclusion(field string, values ...interface{}) *ChangeSet {
+	value, ok := cs.change(field)
+	if !ok {
+		return cs
+	}
+	if !contains(values, value) {
+		cs.AddError(field, "inclusion", "is invalid", map[string]interface{}{
+			"enum": values,
+		})
+	}
+	return cs
+}
+
+func (cs *ChangeSet) ValidateExclusion(field string, values ...interface{}) *ChangeSet {
+	value, ok := cs.change(field)
+	if !ok {
+		return cs
+	}
+	if contains(values, value) {
+		cs.AddError(field, "exclusion", "is reserved", map[string]interface{}{
+			"enum": values,
+		})
+	}
+	return cs
+}
+
+// ValidateConfirmation compares field with the <field>Confirmation param, the error is added on the confirmation.
+func (cs *ChangeSet) ValidateConfirmation(field string) *ChangeSet {
+	value, ok := cs.change(field)
+	if !ok {
+		return cs
+	}
+	confirmField := field + "Confirmation"
+	confirm, ok := cs.Params[confirmField]
+	if !ok || !equalValue(confirm, value) {
+		cs.AddError(confirmField, "confirmation", "does not match confirmation")
+	}
+	return cs
+}
+
+// ValidateChange runs a custom validator on the changed value,
+// a returned *FieldError keeps its code and metadata, any other error is reported as "invalid".
+func (cs *ChangeSet) ValidateChange(field string, validator func(field string, value interface{}) error) *ChangeSet {
+	value, ok := cs.change(field)
+	if !ok {
+		return cs
+	}
+	err := validator(field, value)
+	if err == nil {
+		return cs
+	}
+	if fieldErr, isField := err.(*FieldError); isField {
+		if fieldErr.Field == "" {
+			fieldErr.Field = field
+		}
+		if fieldErr.Meta == nil {
+			fieldErr.Meta = map[string]interface{}{}
+		}
+		cs.Errors = append(cs.Errors, fieldErr)
+		return cs
+	}
+	return cs.AddError(field, "invalid", err.Error())
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// equalValue compares numbers by value whatever their types are.
+func equalValue(a interface{}, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return fa == fb
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equalValue(v, value) {
+			return true
+		}
+	}
+	return false
+}
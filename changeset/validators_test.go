@@ -0,0 +1,55 @@
+package changeset
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	cs := CastClass(&User{}, &UserMsg{
+		UserName:                 "jo",
+		UserEmail:                "not-an-email",
+		UserAge:                  12,
+		UserRole:                 "root",
+		UserPassword:             "secret",
+		UserPasswordConfirmation: "secret!",
+	})
+	cs.ValidateLength("Name", 3, 16).
+		ValidateFormat("Email", regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)).
+		ValidateNumber("Age", 18, math.Inf(1)).
+		ValidateInclusion("Role", "admin", "member").
+		ValidateExclusion("Name", "jo").
+		ValidateConfirmation("Password").
+		ValidateChange("Email", func(field string, value interface{}) error {
+			return errors.New("is banned")
+		})
+	want := map[string][]string{
+		"Name":                 {"should be at least 3 character(s)", "is reserved"},
+		"Email":                {"has invalid format", "is banned"},
+		"Age":                  {"must be greater than or equal to 18"},
+		"Role":                 {"is invalid"},
+		"PasswordConfirmation": {"does not match confirmation"},
+	}
+	if got := cs.TraverseErrors(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestValidatorsSkipUnchanged(t *testing.T) {
+	cs := CastClass(&User{}, &UserMsg{UserName: "john", UserEmail: "john@mail.com"})
+	cs.ValidateNumber("Age", 18, 99).ValidateInclusion("Role", "admin").ValidateConfirmation("Password")
+	if !cs.Valid() {
+		t.Fatalf("unchanged fields must not be validated: %v", cs.TraverseErrors())
+	}
+}
+
+func TestCastClassReportsSize(t *testing.T) {
+	cs := CastClass(&User{}, &UserMsg{UserName: "a name longer than sixteen", UserEmail: "john@mail.com"})
+	errs := cs.ErrorsOn("Name")
+	if len(errs) != 1 || errs[0].Code != "length" || errs[0].Meta["count"] != 16 {
+		t.Fatalf("unexpected errors %v", cs.Errors)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/DSA-JSC/GoEcto
 
 go 1.18
+
+require github.com/go-sql-driver/mysql v1.7.1
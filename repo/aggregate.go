@@ -0,0 +1,54 @@
+package repo
+
+// Count counts the rows where c isn't null, Count(nil) counts every row.
+func Count(c *C) *C {
+	if c == nil {
+		return &C{name: "*", fn: "COUNT"}
+	}
+	return aggregate("COUNT", c)
+}
+
+func Sum(c *C) *C {
+	return aggregate("SUM", c)
+}
+
+func Avg(c *C) *C {
+	return aggregate("AVG", c)
+}
+
+func Min(c *C) *C {
+	return aggregate("MIN", c)
+}
+
+func Max(c *C) *C {
+	return aggregate("MAX", c)
+}
+
+func aggregate(fn string, c *C) *C {
+	return &C{name: c.name, table: c.table, as: c.as, fn: fn}
+}
+
+// Alias refers to a column by the name given with As in Select.
+func Alias(as string) *C {
+	return &C{name: as}
+}
+
+// Raw is an SQL expression used as is, it must not contain user input.
+func Raw(sql string) *C {
+	return &C{raw: sql}
+}
+
+// render quotes the column of table, wrapped in its aggregate function.
+func (c *C) render(d Dialect, table string) string {
+	if c.raw != "" {
+		return c.raw
+	}
+	col := "*"
+	if c.name != "*" {
+		col = quoteCol(d, table, c.name)
+	}
+	if c.fn == "" {
+		return col
+	}
+	return c.fn + "(" + col + ")"
+}
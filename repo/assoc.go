@@ -0,0 +1,367 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+// ErrAssocs is returned by the inserts which don't insert the associations of a changeset, only Save does.
+var ErrAssocs = errors.New("repo: only Save inserts the associations of a changeset")
+
+// Preload loads the declared associations of the queried schemas after All, see Repo.Preload.
+func (q *QueryBuilder) Preload(assocs ...string) *QueryBuilder {
+	q.preloads = append(append([]string{}, q.preloads...), assocs...)
+	return q
+}
+
+// Join adds an INNER JOIN on the table of a declared association of the queried schema,
+// a many_to_many joins through its join table. It panics when the association isn't declared.
+func (q *QueryBuilder) Join(assoc string) *QueryBuilder {
+	return q.join("INNER JOIN", assoc)
+}
+
+// LeftJoin is Join keeping the rows without related rows.
+func (q *QueryBuilder) LeftJoin(assoc string) *QueryBuilder {
+	return q.join("LEFT JOIN", assoc)
+}
+
+func (q *QueryBuilder) join(kind string, assoc string) *QueryBuilder {
+	if q.schema == nil {
+		panic(fmt.Sprintf("repo: can't join %v, the builder has no schema", assoc))
+	}
+	rel, ok := changeset.MetaOf(q.schema).Assoc(assoc)
+	if !ok {
+		panic(fmt.Sprintf("repo: %v has no association %v", q.schema.Name(), assoc))
+	}
+	ownerKey, relatedKey, err := rel.Keys()
+	if err != nil {
+		panic(err.Error())
+	}
+	d := q.dialect
+	if d == nil {
+		d = MySQL
+	}
+	table := changeset.TableOf(rel.Elem)
+	if rel.Assoc == changeset.ManyToMany {
+		q.query += fmt.Sprintf(" %v %v ON %v = %v", kind, d.Quote(rel.JoinTable),
+			quoteCol(d, rel.JoinTable, rel.JoinKeys[0]), quoteCol(d, q.table, ownerKey.Column))
+		q.query += fmt.Sprintf(" %v %v ON %v = %v", kind, d.Quote(table),
+			quoteCol(d, table, relatedKey.Column), quoteCol(d, rel.JoinTable, rel.JoinKeys[1]))
+	} else {
+		q.query += fmt.Sprintf(" %v %v ON %v = %v", kind, d.Quote(table),
+			quoteCol(d, q.table, ownerKey.Column), quoteCol(d, table, relatedKey.Column))
+	}
+	q.joined = true
+	return q
+}
+
+// all runs qb into values of the type of cast, selecting the columns of the schema when qb has no Select,
+// then preloads the associations of qb.
+func (r *Repo) all(ctx context.Context, qb *QueryBuilder, cast interface{}) ([]interface{}, error) {
+	if qb.Projection == nil {
+		// select on a copy, the caller's builder is left as it was
+		qb = qb.clone()
+		for _, f := range changeset.MetaOf(cast).Fields {
+			qb.Select(Col(f.Column, qb.table))
+		}
+	}
+	query, args := qb.Query()
+	results, err := r.query(ctx, query, args, cast)
+	if err != nil {
+		return nil, err
+	}
+	if len(qb.preloads) > 0 {
+		if err := r.Preload(ctx, results, qb.preloads...); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// Preload loads declared associations into schemas, a pointer to a schema or a slice of them,
+// with one query per association (two for a many_to_many). "Posts.Comments" preloads the Comments
+// of the preloaded Posts. The relation fields are replaced, so preloading again doesn't duplicate them.
+func (r *Repo) Preload(ctx context.Context, schemas interface{}, assocs ...string) error {
+	owners := []reflect.Value{}
+	rv := reflect.ValueOf(schemas)
+	switch {
+	case rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct:
+		owners = append(owners, rv.Elem())
+	case rv.Kind() == reflect.Slice:
+		for i := 0; i < rv.Len(); i++ {
+			item := rv.Index(i)
+			for item.Kind() == reflect.Interface || item.Kind() == reflect.Ptr {
+				item = item.Elem()
+			}
+			if item.IsValid() {
+				owners = append(owners, item)
+			}
+		}
+	default:
+		return fmt.Errorf("repo: Preload needs a pointer to a schema or a slice of schemas, got %T", schemas)
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	meta := changeset.MetaOf(owners[0].Type())
+	for _, path := range assocs {
+		name, nested := path, ""
+		if i := strings.Index(path, "."); i >= 0 {
+			name, nested = path[:i], path[i+1:]
+		}
+		rel, ok := meta.Assoc(name)
+		if !ok {
+			return fmt.Errorf("repo: %v has no association %v", meta.Type.Name(), name)
+		}
+		related, err := r.preload(ctx, owners, rel)
+		if err != nil {
+			return err
+		}
+		if nested != "" {
+			if err := r.Preload(ctx, related, nested); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// preload loads rel for owners and returns the related schemas.
+func (r *Repo) preload(ctx context.Context, owners []reflect.Value, rel *changeset.RelationMeta) ([]interface{}, error) {
+	ownerKey, relatedKey, err := rel.Keys()
+	if err != nil {
+		return nil, err
+	}
+	for _, owner := range owners {
+		field := owner.FieldByIndex(rel.Index)
+		field.Set(reflect.Zero(field.Type()))
+	}
+	keys := keyValues(owners, ownerKey)
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	// the column of the related schema matched with the keys of the owners
+	matchKey := relatedKey
+	var joins []*joinRow
+	if rel.Assoc == changeset.ManyToMany {
+		if joins, err = r.joinRows(ctx, rel, keys); err != nil {
+			return nil, err
+		}
+		if len(joins) == 0 {
+			return nil, nil
+		}
+		relatedIds := []interface{}{}
+		seen := map[string]bool{}
+		for _, join := range joins {
+			if k := keyOf(join.Related); !seen[k] {
+				seen[k] = true
+				relatedIds = append(relatedIds, join.Related)
+			}
+		}
+		keys = relatedIds
+	}
+	qb := r.GetById(reflect.New(rel.Elem).Interface())
+	qb.Where(P(matchKey.Column, qb.table, In, keys))
+	if pk := changeset.MetaOf(rel.Elem).PK; pk != nil {
+		qb.OrderBy(Col(pk.Column, qb.table), ASC)
+	}
+	related, err := r.all(ctx, qb, reflect.New(rel.Elem).Interface())
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string][]reflect.Value{}
+	for _, item := range related {
+		value := reflect.ValueOf(item)
+		k := keyOf(value.Elem().FieldByIndex(matchKey.Index).Interface())
+		byKey[k] = append(byKey[k], value)
+	}
+	for _, owner := range owners {
+		k := keyOf(owner.FieldByIndex(ownerKey.Index).Interface())
+		if rel.Assoc != changeset.ManyToMany {
+			setRelated(owner, rel, byKey[k])
+			continue
+		}
+		items := []reflect.Value{}
+		for _, join := range joins {
+			if keyOf(join.Owner) == k {
+				items = append(items, byKey[keyOf(join.Related)]...)
+			}
+		}
+		setRelated(owner, rel, items)
+	}
+	return related, nil
+}
+
+// joinRow is a row of the join table of a many_to_many.
+type joinRow struct {
+	Owner   interface{}
+	Related interface{}
+}
+
+func (r *Repo) joinRows(ctx context.Context, rel *changeset.RelationMeta, keys []interface{}) ([]*joinRow, error) {
+	qb := &QueryBuilder{
+		query:   fmt.Sprintf("FROM %v", r.dialect.Quote(rel.JoinTable)),
+		table:   rel.JoinTable,
+		dialect: r.dialect,
+	}
+	// quoted aliases keep their case on postgres
+	qb.Select(Col(rel.JoinKeys[0], rel.JoinTable).As("Owner"))
+	qb.Select(Col(rel.JoinKeys[1], rel.JoinTable).As("Related"))
+	qb.Where(P(rel.JoinKeys[0], rel.JoinTable, In, keys))
+	query, args := qb.Query()
+	results, err := r.query(ctx, query, args, &joinRow{})
+	if err != nil {
+		return nil, err
+	}
+	joins := make([]*joinRow, len(results))
+	for i, result := range results {
+		joins[i] = result.(*joinRow)
+	}
+	return joins, nil
+}
+
+// setRelated sets the relation field of owner to items, pointers to related schemas.
+func setRelated(owner reflect.Value, rel *changeset.RelationMeta, items []reflect.Value) {
+	field := owner.FieldByIndex(rel.Index)
+	if !rel.Many {
+		if len(items) > 0 {
+			field.Set(items[0])
+		}
+		return
+	}
+	values := reflect.MakeSlice(field.Type(), 0, len(items))
+	for _, item := range items {
+		if field.Type().Elem().Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		values = reflect.Append(values, item)
+	}
+	field.Set(values)
+}
+
+// keyValues returns the distinct non zero values of the field f of schemas.
+func keyValues(schemas []reflect.Value, f *changeset.FieldMeta) []interface{} {
+	values := []interface{}{}
+	seen := map[string]bool{}
+	for _, schema := range schemas {
+		value := schema.FieldByIndex(f.Index)
+		if value.IsZero() {
+			continue
+		}
+		if k := keyOf(value.Interface()); !seen[k] {
+			seen[k] = true
+			values = append(values, reflect.Indirect(value).Interface())
+		}
+	}
+	return values
+}
+
+// keyOf compares keys of different Go types, a uint32 id and the int64 read from a join table are the same key.
+func keyOf(value interface{}) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(rv.Interface())
+}
+
+// saveAssocs inserts cs and its associations in a transaction: the belongs_to schemas first so cs
+// gets their keys, then cs, then the has_one, has_many and many_to_many schemas with the key of cs.
+// A belongs_to or many_to_many schema with its primary key set already exists, it's only linked.
+func (r *Repo) saveAssocs(ctx context.Context, cs *changeset.ChangeSet, onConflict ...*OnConflict) error {
+	if len(onConflict) > 0 && onConflict[0] != nil {
+		// the id of a kept row isn't read back, the associations would have no key
+		return fmt.Errorf("repo: Save can't insert the associations of an upsert")
+	}
+	if err := validUpdate(cs); err != nil {
+		return err
+	}
+	meta := changeset.MetaOf(cs.ReflectSchema.Type())
+	names := make([]string, 0, len(cs.Assocs))
+	for name := range cs.Assocs {
+		if _, ok := meta.Assoc(name); !ok {
+			return fmt.Errorf("repo: %v has no association %v", meta.Type.Name(), name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return r.Transaction(ctx, nil, func(tx *Repo) error {
+		for _, name := range names {
+			rel := meta.Relations[name]
+			if rel.Assoc != changeset.BelongsTo {
+				continue
+			}
+			ownerKey, relatedKey, err := rel.Keys()
+			if err != nil {
+				return err
+			}
+			for _, child := range cs.Assocs[name] {
+				if !saved(child) {
+					if err := tx.Save(ctx, child); err != nil {
+						return err
+					}
+				}
+				cs.PutChange(ownerKey.Name, child.ReflectSchema.FieldByIndex(relatedKey.Index).Interface())
+			}
+		}
+		if err := tx.insert(ctx, cs); err != nil {
+			return err
+		}
+		for _, name := range names {
+			rel := meta.Relations[name]
+			if rel.Assoc == changeset.BelongsTo {
+				continue
+			}
+			ownerKey, relatedKey, err := rel.Keys()
+			if err != nil {
+				return err
+			}
+			key := cs.ReflectSchema.FieldByIndex(ownerKey.Index).Interface()
+			for _, child := range cs.Assocs[name] {
+				if rel.Assoc != changeset.ManyToMany {
+					child.PutChange(relatedKey.Name, key)
+				}
+				if rel.Assoc != changeset.ManyToMany || !saved(child) {
+					if err := tx.Save(ctx, child); err != nil {
+						return err
+					}
+				}
+				if rel.Assoc == changeset.ManyToMany {
+					if err := tx.insertJoin(ctx, rel, key, child.ReflectSchema.FieldByIndex(relatedKey.Index).Interface()); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// saved tells if the row of cs exists, its primary key is set.
+func saved(cs *changeset.ChangeSet) bool {
+	pk := changeset.MetaOf(cs.ReflectSchema.Type()).PK
+	return pk != nil && !cs.ReflectSchema.FieldByIndex(pk.Index).IsZero()
+}
+
+// insertJoin links two schemas of a many_to_many with a row of its join table.
+func (r *Repo) insertJoin(ctx context.Context, rel *changeset.RelationMeta, owner interface{}, related interface{}) error {
+	d := r.dialect
+	query := fmt.Sprintf("INSERT INTO %v (%v, %v) VALUES (?, ?)", d.Quote(rel.JoinTable), d.Quote(rel.JoinKeys[0]), d.Quote(rel.JoinKeys[1]))
+	_, err := r.exec(ctx, r.conn, nil, d.Rebind(query), []interface{}{owner, related})
+	return err
+}
@@ -0,0 +1,260 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+type Writer struct {
+	Id      uint32   `ecto:"pk,autoincrement"`
+	Name    string   `ecto:"notnull"`
+	Profile *Profile `ecto:"has_one"`
+	Books   []*Book  `ecto:"has_many"`
+}
+
+type Profile struct {
+	Id       uint32 `ecto:"pk,autoincrement"`
+	Bio      string
+	WriterId uint32
+}
+
+type Book struct {
+	Id       uint32   `ecto:"pk,autoincrement"`
+	Title    string   `ecto:"notnull"`
+	WriterId uint32   `ecto:"notnull"`
+	Writer   *Writer  `ecto:"belongs_to"`
+	Genres   []*Genre `ecto:"many_to_many,join_table:books_genres,join_keys:BookId|GenreId"`
+}
+
+type Genre struct {
+	Id   uint32 `ecto:"pk,autoincrement"`
+	Name string
+}
+
+type Shelf struct {
+	Serial uint64 `ecto:"pk,autoincrement"`
+	Name   string
+	Items  []*Item `ecto:"has_many,foreign_key:ShelfSerial"`
+}
+
+type Item struct {
+	Id          uint32 `ecto:"pk,autoincrement"`
+	Name        string
+	ShelfSerial uint64
+}
+
+type Store struct {
+	Id          uint32 `ecto:"pk,autoincrement"`
+	Name        string
+	CountryCode string
+	Country     *Country `ecto:"belongs_to,foreign_key:CountryCode"`
+}
+
+func TestPreload(t *testing.T) {
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		switch {
+		case strings.Contains(query, "FROM `writers`"):
+			return rowsOf("Id,Name", []driver.Value{int64(1), "ann"}, []driver.Value{int64(2), "bob"})
+		case strings.Contains(query, "FROM `books`"):
+			return rowsOf("Id,Title,WriterId",
+				[]driver.Value{int64(10), "Go", int64(1)},
+				[]driver.Value{int64(11), "SQL", int64(2)},
+				[]driver.Value{int64(12), "Ecto", int64(1)},
+			)
+		case strings.Contains(query, "FROM `books_genres`"):
+			return rowsOf("Owner,Related", []driver.Value{int64(10), int64(7)}, []driver.Value{int64(12), int64(7)})
+		case strings.Contains(query, "FROM `genres`"):
+			return rowsOf("Id,Name", []driver.Value{int64(7), "tech"})
+		}
+		return rowsOf("Id")
+	})
+	defer r.Close()
+	writers, err := All[Writer](context.Background(), r, From[Writer](r).Preload("Books.Genres", "Profile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann, bob := writers[0], writers[1]
+	if len(ann.Books) != 2 || ann.Books[0].Title != "Go" || ann.Books[1].Title != "Ecto" || len(bob.Books) != 1 {
+		t.Fatalf("books not preloaded: %+v %+v", ann.Books, bob.Books)
+	}
+	if len(ann.Books[0].Genres) != 1 || ann.Books[1].Genres[0] != ann.Books[0].Genres[0] || len(bob.Books[0].Genres) != 0 {
+		t.Fatalf("genres not preloaded")
+	}
+	if ann.Profile != nil {
+		t.Fatalf("unexpected profile %+v", ann.Profile)
+	}
+	queries := db.Queries()
+	want := []string{
+		" SELECT `books`.`Id`, `books`.`Title`, `books`.`WriterId` FROM `books` WHERE `books`.`WriterId` IN (?, ?) ORDER BY `books`.`Id` ASC",
+		" SELECT `books_genres`.`BookId` AS `Owner`, `books_genres`.`GenreId` AS `Related` FROM `books_genres` WHERE `books_genres`.`BookId` IN (?, ?, ?)",
+		" SELECT `genres`.`Id`, `genres`.`Name` FROM `genres` WHERE `genres`.`Id` IN (?) ORDER BY `genres`.`Id` ASC",
+	}
+	if len(queries) != 5 || !reflect.DeepEqual(queries[1:4], want) {
+		t.Fatalf("unexpected queries %q", queries)
+	}
+
+	book := &Book{Id: 10, WriterId: 2}
+	if err := r.Preload(context.Background(), book, "Writer"); err != nil {
+		t.Fatal(err)
+	}
+	if book.Writer == nil || book.Writer.Name != "bob" {
+		t.Fatalf("writer not preloaded: %+v", book.Writer)
+	}
+	if err := r.Preload(context.Background(), book, "Title"); err == nil {
+		t.Fatalf("preloading a field must fail")
+	}
+}
+
+func TestJoin(t *testing.T) {
+	r, _ := newFakeRepo(Postgres, nil)
+	defer r.Close()
+	query, _ := From[Book](r).Join("Writer").LeftJoin("Genres").Query()
+	want := `FROM "books" INNER JOIN "writers" ON "books"."WriterId" = "writers"."Id"` +
+		` LEFT JOIN "books_genres" ON "books_genres"."BookId" = "books"."Id"` +
+		` LEFT JOIN "genres" ON "genres"."Id" = "books_genres"."GenreId"`
+	if query != want {
+		t.Fatalf("got %q, want %q", query, want)
+	}
+	qb := From[Writer](r).Join("Books")
+	if query, _ = qb.Query(); query != `FROM "writers" INNER JOIN "books" ON "writers"."Id" = "books"."WriterId"` || !qb.joined {
+		t.Fatalf("unexpected join %q", query)
+	}
+}
+
+func TestSaveAssocs(t *testing.T) {
+	var id int64
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		if strings.HasPrefix(query, "INSERT") {
+			id++
+			return &fakeResult{rowsAffected: 1, lastInsertId: id}
+		}
+		return nil
+	})
+	defer r.Close()
+	book := &Book{}
+	cs := changeset.CastValues(book, map[string]interface{}{
+		"title":  "Go",
+		"writer": map[string]interface{}{"Name": "ann"},
+		"genres": []interface{}{map[string]interface{}{"Name": "tech"}},
+	})
+	if len(cs.Errors) > 0 || book.Writer == nil || len(book.Genres) != 1 {
+		t.Fatalf("associations not cast: %v %+v", cs.TraverseErrors(), book)
+	}
+	if err := r.Save(context.Background(), cs); err != nil {
+		t.Fatal(err)
+	}
+	if book.Writer.Id != 1 || book.WriterId != 1 || book.Id != 2 || book.Genres[0].Id != 3 {
+		t.Fatalf("ids not set: %+v %+v %+v", book, book.Writer, book.Genres[0])
+	}
+	queries := db.Queries()
+	if queries[0] != "BEGIN" || queries[len(queries)-1] != "COMMIT" {
+		t.Fatalf("associations must be saved in a transaction: %q", queries)
+	}
+	last := len(db.args) - 2
+	if queries[last] != "INSERT INTO `books_genres` (`BookId`, `GenreId`) VALUES (?, ?)" || !reflect.DeepEqual(db.args[last], []interface{}{int64(2), int64(3)}) {
+		t.Fatalf("join row not inserted: %q %v", queries[last], db.args[last])
+	}
+
+	writer := &Writer{}
+	cs = changeset.CastValues(writer, map[string]interface{}{"Name": "bob", "Books": []interface{}{map[string]interface{}{}}})
+	if err := r.Save(context.Background(), cs); err == nil {
+		t.Fatalf("a book without title must fail, got %v", err)
+	}
+	if got := db.Queries(); got[len(got)-1] != "ROLLBACK" {
+		t.Fatalf("expected a rollback, got %q", got)
+	}
+	if err := r.Save(context.Background(), cs, OnConflictNothing()); err == nil {
+		t.Fatalf("an upsert with associations must fail")
+	}
+}
+
+func TestSaveAssocsLinksSaved(t *testing.T) {
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		return &fakeResult{rowsAffected: 1, lastInsertId: 8}
+	})
+	defer r.Close()
+	book := &Book{}
+	cs := changeset.CastValues(book, map[string]interface{}{"Title": "Go"}).
+		PutAssoc("Writer", changeset.CastValues(&Writer{Id: 3}, map[string]interface{}{"Name": "ann"})).
+		PutAssoc("Genres", changeset.CastValues(&Genre{Id: 5}, map[string]interface{}{"Name": "tech"}))
+	if err := r.Save(context.Background(), cs); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"BEGIN",
+		"INSERT INTO `books` (`Title`, `WriterId`) VALUES (?, ?)",
+		"INSERT INTO `books_genres` (`BookId`, `GenreId`) VALUES (?, ?)",
+		"COMMIT",
+	}
+	if got := db.Queries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if book.WriterId != 3 || !reflect.DeepEqual(db.args[2], []interface{}{int64(8), int64(5)}) {
+		t.Fatalf("saved associations not linked: %+v %v", book, db.args[2])
+	}
+}
+
+func TestSaveAssocsDeclaredKey(t *testing.T) {
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		return &fakeResult{rowsAffected: 1, lastInsertId: 9}
+	})
+	defer r.Close()
+	shelf := &Shelf{}
+	cs := changeset.CastValues(shelf, map[string]interface{}{"Name": "top", "Items": []interface{}{map[string]interface{}{"Name": "cup"}}})
+	if err := r.Save(context.Background(), cs); err != nil {
+		t.Fatal(err)
+	}
+	if shelf.Serial != 9 || shelf.Items[0].ShelfSerial != 9 {
+		t.Fatalf("key of the shelf not set: %+v %+v", shelf, shelf.Items[0])
+	}
+	if got := db.Queries()[2]; got != "INSERT INTO `items` (`Name`, `ShelfSerial`) VALUES (?, ?)" {
+		t.Fatalf("unexpected insert %q", got)
+	}
+
+	ctx := context.Background()
+	cs = changeset.CastValues(&Shelf{}, map[string]interface{}{"Name": "top", "Items": []interface{}{map[string]interface{}{"Name": "cup"}}})
+	tx, err := r.OpenTx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := r.SaveTx(ctx, cs, tx); !errors.Is(err, ErrAssocs) {
+		t.Fatalf("SaveTx must reject associations, got %v", err)
+	}
+	if _, _, err := r.InsertAll(ctx, &Shelf{}, []*changeset.ChangeSet{cs}); !errors.Is(err, ErrAssocs) {
+		t.Fatalf("InsertAll must reject associations, got %v", err)
+	}
+	results, err := r.ExecMulti(ctx, NewMulti().Insert("shelf", cs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := results["shelf"].(*Shelf).Items; items[0].Id != 9 || items[0].ShelfSerial != 9 {
+		t.Fatalf("a Multi insert must insert the associations: %+v", items[0])
+	}
+}
+
+func TestSaveAssocsLinksSavedStringKey(t *testing.T) {
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		return &fakeResult{rowsAffected: 1, lastInsertId: 4}
+	})
+	defer r.Close()
+	store := &Store{}
+	cs := changeset.CastValues(store, map[string]interface{}{"Name": "Paris"}).
+		PutAssoc("Country", changeset.CastValues(&Country{}, map[string]interface{}{"Code": "fr", "Name": "France"}))
+	if err := r.Save(context.Background(), cs); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BEGIN", "INSERT INTO `stores` (`Name`, `CountryCode`) VALUES (?, ?)", "COMMIT"}
+	if got := db.Queries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if store.CountryCode != "fr" || store.Id != 4 {
+		t.Fatalf("country not linked: %+v", store)
+	}
+}
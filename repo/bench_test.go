@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+type Post struct {
+	Id        uint32
+	Title     string
+	Body      string
+	Views     uint32
+	AccountId uint32
+	Meta      *PostMeta
+}
+
+type PostMeta struct {
+	Tags []string `json:"tags"`
+}
+
+func (p *Post) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":        changeset.NewBox().Ops(changeset.AI),
+		"Title":     changeset.NewBox().Ops(changeset.NotNullable).Size(128),
+		"Body":      changeset.NewBox().Size(4096),
+		"Views":     changeset.NewBox(),
+		"AccountId": changeset.NewBox(),
+		"Meta":      changeset.NewBox().JSONField(),
+	}
+}
+
+func postRows(n int) *fakeResult {
+	rows := make([][]driver.Value, n)
+	for i := range rows {
+		rows[i] = []driver.Value{int64(i + 1), "title", "body", int64(i), int64(7), []byte(`{"tags":["go","sql"]}`)}
+	}
+	return rowsOf("Id,Title,Body,Views,AccountId,Meta", rows...)
+}
+
+func BenchmarkParseToStruct(b *testing.B) {
+	res := postRows(100)
+	r, _ := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		res.rows = postRows(100).rows
+		return res
+	})
+	defer r.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := r.db.QueryContext(context.Background(), "SELECT * FROM `posts`")
+		if err != nil {
+			b.Fatal(err)
+		}
+		results, err := r.ParseToStruct(rows, &Post{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		rows.Close()
+		if len(results) != 100 {
+			b.Fatalf("got %v results", len(results))
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Violation is a constraint error reported by the driver, Name is empty when the engine only reports columns.
+type Violation struct {
+	Kind    changeset.ConstraintKind
+	Name    string
+	Columns []string
+}
+
+// constraintError converts err into a changeset error when the changeset declared the violated constraint,
+// other errors are returned untouched.
+// On delete a foreign key violation is always a row still referencing the deleted one.
+func (r *Repo) constraintError(cs *changeset.ChangeSet, err error, action ...changeset.ActionRepo) error {
+	v := r.dialect.Violation(err)
+	if v == nil {
+		return err
+	}
+	if len(action) > 0 && action[0] == changeset.ActionDelete && v.Kind == changeset.ConstraintForeignKey {
+		v.Kind = changeset.ConstraintNoAssoc
+	}
+	if cs.ConstraintError(v.Kind, v.Name, v.Columns) {
+		return &changeset.ValidationError{ChangeSet: cs}
+	}
+	return err
+}
+
+// ValidateUnique queries for another row having the same values of fields and adds a "unique" error when found.
+// It only gives early feedback, declare a UniqueConstraint too since a concurrent insert can pass the check.
+func (r *Repo) ValidateUnique(ctx context.Context, cs *changeset.ChangeSet, fields ...string) error {
+	changed := false
+	for _, col := range cs.CastedBoxes {
+		for _, field := range fields {
+			changed = changed || col == field
+		}
+	}
+	if !changed {
+		return nil
+	}
+	d := r.dialect
+	query := fmt.Sprintf("SELECT 1 FROM %v WHERE ", d.Quote(cs.TableName()))
+	args := []interface{}{}
+	for i, field := range fields {
+		f := cs.ReflectSchema.FieldByName(field)
+		if !f.IsValid() {
+			return fmt.Errorf("repo: %v has no field %v", cs.ReflectSchema.Type().Name(), field)
+		}
+		if i > 0 {
+			query += " AND "
+		}
+		query += fmt.Sprintf("%v = ?", d.Quote(cs.Column(field)))
+		args = append(args, f.Interface())
+	}
+	if pk := changeset.MetaOf(cs.ReflectSchema.Type()).PK; pk != nil {
+		if id := cs.ReflectSchema.FieldByIndex(pk.Index); !id.IsZero() {
+			// an update must not conflict with its own row
+			query += fmt.Sprintf(" AND %v <> ?", d.Quote(pk.Column))
+			args = append(args, id.Interface())
+		}
+	}
+	query += " " + d.LimitOffset(1, 0)
+	var found int
+	query = d.Rebind(query)
+	start := time.Now()
+	err := r.conn.QueryRowContext(ctx, query, args...).Scan(&found)
+	event := &QueryEvent{Query: query, Args: args, RowsAffected: int64(found)}
+	if err != sql.ErrNoRows {
+		event.Err = err
+	}
+	r.logQuery(ctx, start, cs, event)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cs.AddError(fields[0], "unique", "has already been taken", map[string]interface{}{
+		"validation": "unsafe_unique",
+		"fields":     fields,
+	})
+	return nil
+}
+
+func mysqlViolation(err error) *Violation {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return nil
+	}
+	switch myErr.Number {
+	case 1062:
+		// Duplicate entry 'x' for key 'users.users_email_index', the table prefix exists since 8.0.19
+		name := quotedAfter(myErr.Message, "for key ", '\'')
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		return &Violation{Kind: changeset.ConstraintUnique, Name: name}
+	case 1451:
+		// Cannot delete or update a parent row: a foreign key constraint fails (`db`.`posts`, CONSTRAINT `posts_userid_fkey` ...)
+		return &Violation{Kind: changeset.ConstraintNoAssoc, Name: quotedAfter(myErr.Message, "CONSTRAINT ", '`')}
+	case 1452:
+		return &Violation{Kind: changeset.ConstraintForeignKey, Name: quotedAfter(myErr.Message, "CONSTRAINT ", '`')}
+	case 3819:
+		// Check constraint 'users_age_check' is violated.
+		return &Violation{Kind: changeset.ConstraintCheck, Name: quotedAfter(myErr.Message, "constraint ", '\'')}
+	case 1048:
+		// Column 'Email' cannot be null
+		return &Violation{Kind: changeset.ConstraintNotNull, Columns: []string{quotedAfter(myErr.Message, "Column ", '\'')}}
+	}
+	return nil
+}
+
+// sqlStater is implemented by the postgres driver errors (pgconn.PgError, pq.Error).
+type sqlStater interface {
+	SQLState() string
+}
+
+func postgresViolation(err error) *Violation {
+	code := ""
+	var stater sqlStater
+	if errors.As(err, &stater) {
+		code = stater.SQLState()
+	}
+	msg := err.Error()
+	switch {
+	case code == "23505" || strings.Contains(msg, "violates unique constraint"):
+		return &Violation{Kind: changeset.ConstraintUnique, Name: quotedAfter(msg, "unique constraint ", '"')}
+	case code == "23503" || strings.Contains(msg, "violates foreign key constraint"):
+		kind := changeset.ConstraintForeignKey
+		if strings.Contains(msg, "update or delete on table") {
+			kind = changeset.ConstraintNoAssoc
+		}
+		return &Violation{Kind: kind, Name: quotedAfter(msg, "foreign key constraint ", '"')}
+	case code == "23514" || strings.Contains(msg, "violates check constraint"):
+		return &Violation{Kind: changeset.ConstraintCheck, Name: quotedAfter(msg, "check constraint ", '"')}
+	case code == "23502" || strings.Contains(msg, "violates not-null constraint"):
+		return &Violation{Kind: changeset.ConstraintNotNull, Columns: []string{quotedAfter(msg, "column ", '"')}}
+	}
+	return nil
+}
+
+func sqliteViolation(err error) *Violation {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "UNIQUE constraint failed: "):
+		return &Violation{Kind: changeset.ConstraintUnique, Columns: sqliteColumns(msg, "UNIQUE constraint failed: ")}
+	case strings.Contains(msg, "FOREIGN KEY constraint failed"):
+		// sqlite reports neither the name nor the columns of a foreign key
+		return &Violation{Kind: changeset.ConstraintForeignKey}
+	case strings.Contains(msg, "CHECK constraint failed: "):
+		name := msg[strings.Index(msg, "CHECK constraint failed: ")+len("CHECK constraint failed: "):]
+		if i := strings.Index(name, " ("); i >= 0 {
+			name = name[:i]
+		}
+		return &Violation{Kind: changeset.ConstraintCheck, Name: name}
+	case strings.Contains(msg, "NOT NULL constraint failed: "):
+		return &Violation{Kind: changeset.ConstraintNotNull, Columns: sqliteColumns(msg, "NOT NULL constraint failed: ")}
+	}
+	return nil
+}
+
+// quotedAfter returns the text quoted by quote right after prefix in msg.
+func quotedAfter(msg string, prefix string, quote byte) string {
+	i := strings.Index(msg, prefix+string(quote))
+	if i < 0 {
+		return ""
+	}
+	rest := msg[i+len(prefix)+1:]
+	j := strings.IndexByte(rest, quote)
+	if j < 0 {
+		return ""
+	}
+	return rest[:j]
+}
+
+// sqliteColumns parses "users.email, users.name" after prefix into [email name].
+func sqliteColumns(msg string, prefix string) []string {
+	rest := msg[strings.Index(msg, prefix)+len(prefix):]
+	if i := strings.Index(rest, " ("); i >= 0 {
+		// modernc.org/sqlite appends the extended code, "users.email (2067)"
+		rest = rest[:i]
+	}
+	cols := []string{}
+	for _, col := range strings.Split(rest, ",") {
+		col = strings.TrimSpace(col)
+		if i := strings.LastIndex(col, "."); i >= 0 {
+			col = col[i+1:]
+		}
+		if col != "" {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
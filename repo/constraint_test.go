@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+	"github.com/go-sql-driver/mysql"
+)
+
+type pgError struct {
+	code string
+	msg  string
+}
+
+func (e *pgError) Error() string    { return e.msg }
+func (e *pgError) SQLState() string { return e.code }
+
+func TestUniqueViolation(t *testing.T) {
+	for _, tc := range []struct {
+		dialect Dialect
+		err     error
+	}{
+		{MySQL, &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@b.c' for key 'accounts.accounts_email_index'"}},
+		{MySQL, fmt.Errorf("save: %w", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@b.c' for key 'accounts_email_index'"})},
+		{Postgres, &pgError{code: "23505", msg: `ERROR: duplicate key value violates unique constraint "accounts_email_index" (SQLSTATE 23505)`}},
+		{SQLite, errors.New("UNIQUE constraint failed: accounts.Email")},
+	} {
+		cs := changeset.CastClass(&Account{}, &AccountMsg{AccountName: "john", AccountEmail: "a@b.c"})
+		cs.UniqueConstraint("Email")
+		r := NewRepoDB(nil, tc.dialect)
+		err := r.constraintError(cs, tc.err)
+		if !errors.Is(err, changeset.ErrInvalid) {
+			t.Fatalf("%v: %v not mapped", tc.dialect.Name(), tc.err)
+		}
+		if errs := cs.ErrorsOn("Email"); len(errs) != 1 || errs[0].Code != "unique" {
+			t.Fatalf("%v: unexpected errors %v", tc.dialect.Name(), cs.Errors)
+		}
+	}
+}
+
+func TestUndeclaredViolation(t *testing.T) {
+	cs := changeset.CastClass(&Account{}, &AccountMsg{AccountName: "john", AccountEmail: "a@b.c"})
+	raw := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'john' for key 'accounts.accounts_name_index'"}
+	if err := NewRepoDB(nil, MySQL).constraintError(cs, raw); err != raw {
+		t.Fatalf("undeclared constraint must return the driver error, got %v", err)
+	}
+}
+
+func TestForeignKeyCheckAndNotNullViolations(t *testing.T) {
+	for _, tc := range []struct {
+		dialect Dialect
+		err     error
+		field   string
+		code    string
+	}{
+		{MySQL, &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`app`.`accounts`, CONSTRAINT `accounts_name_fkey` FOREIGN KEY (`Name`) REFERENCES `names` (`Id`))"}, "Name", "foreign"},
+		{MySQL, &mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row: a foreign key constraint fails (`app`.`posts`, CONSTRAINT `posts_accountid_fkey` FOREIGN KEY (`AccountId`) REFERENCES `accounts` (`Id`))"}, "Posts", "no_assoc"},
+		{MySQL, &mysql.MySQLError{Number: 3819, Message: "Check constraint 'email_has_at' is violated."}, "Email", "check"},
+		{MySQL, &mysql.MySQLError{Number: 1048, Message: "Column 'Email' cannot be null"}, "Email", "required"},
+		{Postgres, &pgError{code: "23503", msg: `pq: insert or update on table "accounts" violates foreign key constraint "accounts_name_fkey"`}, "Name", "foreign"},
+		{Postgres, &pgError{code: "23503", msg: `pq: update or delete on table "accounts" violates foreign key constraint "posts_accountid_fkey" on table "posts"`}, "Posts", "no_assoc"},
+		{Postgres, &pgError{code: "23514", msg: `pq: new row for relation "accounts" violates check constraint "email_has_at"`}, "Email", "check"},
+		{Postgres, &pgError{code: "23502", msg: `pq: null value in column "Email" of relation "accounts" violates not-null constraint`}, "Email", "required"},
+		{SQLite, errors.New("FOREIGN KEY constraint failed"), "Name", "foreign"},
+		{SQLite, errors.New("CHECK constraint failed: email_has_at"), "Email", "check"},
+		{SQLite, errors.New("NOT NULL constraint failed: accounts.Email"), "Email", "required"},
+	} {
+		cs := changeset.CastClass(&Account{}, &AccountMsg{AccountName: "john", AccountEmail: "a@b.c"})
+		cs.ForeignKeyConstraint("Name")
+		cs.NoAssocConstraint("Posts")
+		cs.CheckConstraint("Email", "email_has_at")
+		err := NewRepoDB(nil, tc.dialect).constraintError(cs, tc.err)
+		if !errors.Is(err, changeset.ErrInvalid) {
+			t.Fatalf("%v: %v not mapped", tc.dialect.Name(), tc.err)
+		}
+		if errs := cs.ErrorsOn(tc.field); len(errs) != 1 || errs[0].Code != tc.code {
+			t.Fatalf("%v: %v: unexpected errors %v", tc.dialect.Name(), tc.err, cs.TraverseErrors())
+		}
+	}
+}
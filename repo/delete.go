@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+var ErrNotFound = errors.New("repo: no row matched the primary key")
+
+// ErrNoPrimaryKey is returned when a row is updated or deleted by the primary key of a schema without one.
+var ErrNoPrimaryKey = errors.New("repo: the schema has no primary key")
+
+// Delete deletes the row of a schema or of a changeset by its primary key.
+// The constraints declared on the changeset map the driver errors, ErrNotFound is returned when no row is deleted.
+func (r *Repo) Delete(ctx context.Context, schemaOrChangeset interface{}) error {
+	return r.delete(ctx, toChangeSet(schemaOrChangeset), r.conn)
+}
+
+func (r *Repo) DeleteTx(ctx context.Context, schemaOrChangeset interface{}, tx *sql.Tx) error {
+	return r.delete(ctx, toChangeSet(schemaOrChangeset), tx)
+}
+
+func toChangeSet(schemaOrChangeset interface{}) *changeset.ChangeSet {
+	if cs, ok := schemaOrChangeset.(*changeset.ChangeSet); ok {
+		return cs
+	}
+	return changeset.Change(schemaOrChangeset)
+}
+
+func (r *Repo) delete(ctx context.Context, cs *changeset.ChangeSet, conn executor) error {
+	if err := validUpdate(cs); err != nil {
+		return err
+	}
+	query, args, err := DeleteQuery(cs, r.dialect)
+	if err != nil {
+		return err
+	}
+	affected, err := r.exec(ctx, conn, cs, query, args)
+	if err != nil {
+		return r.constraintError(cs, err, changeset.ActionDelete)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	cs.ActionRepo = changeset.ActionDelete
+	return nil
+}
+
+func DeleteQuery(cs *changeset.ChangeSet, dialect ...Dialect) (string, []interface{}, error) {
+	d := MySQL
+	if len(dialect) > 0 && dialect[0] != nil {
+		d = dialect[0]
+	}
+	pk := changeset.MetaOf(cs.ReflectSchema.Type()).PK
+	if pk == nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrNoPrimaryKey, cs.ReflectSchema.Type().Name())
+	}
+	query := fmt.Sprintf("DELETE FROM %v WHERE %v = ?", d.Quote(cs.TableName()), d.Quote(pk.Column))
+	return d.Rebind(query), []interface{}{cs.ReflectSchema.FieldByIndex(pk.Index).Interface()}, nil
+}
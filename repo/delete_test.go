@@ -0,0 +1,58 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+func TestDelete(t *testing.T) {
+	r, db := newFakeRepo(Postgres, nil)
+	defer r.Close()
+	if err := r.Delete(context.Background(), &Account{Id: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Queries(); got[0] != `DELETE FROM "accounts" WHERE "Id" = $1` || db.args[0][0] != int64(7) {
+		t.Fatalf("unexpected query %v %v", got, db.args)
+	}
+}
+
+func TestDeleteNotFound(t *testing.T) {
+	r, _ := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		return &fakeResult{rowsAffected: 0}
+	})
+	defer r.Close()
+	cs := changeset.Change(&Account{Id: 7})
+	if err := r.Delete(context.Background(), cs); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if cs.ActionRepo == changeset.ActionDelete {
+		t.Fatalf("a failed delete must not set ActionDelete")
+	}
+}
+
+func TestDeleteNoAssoc(t *testing.T) {
+	r, _ := newFakeRepo(SQLite, func(query string, args []interface{}) *fakeResult {
+		if strings.HasPrefix(query, "DELETE") {
+			return &fakeResult{err: errors.New("FOREIGN KEY constraint failed")}
+		}
+		return nil
+	})
+	defer r.Close()
+	tx, err := r.DB().BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	cs := changeset.Change(&Account{Id: 7})
+	cs.NoAssocConstraint("Posts")
+	if err := r.DeleteTx(context.Background(), cs, tx); !errors.Is(err, changeset.ErrInvalid) {
+		t.Fatalf("expected a changeset error, got %v", err)
+	}
+	if errs := cs.ErrorsOn("Posts"); len(errs) != 1 || errs[0].Code != "no_assoc" {
+		t.Fatalf("unexpected errors %v", cs.TraverseErrors())
+	}
+}
@@ -0,0 +1,284 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InsertIdStrategy tells the Repo how to read back the generated primary key of an INSERT.
+type InsertIdStrategy uint8
+
+const (
+	LastInsertId InsertIdStrategy = iota + 1
+	Returning
+)
+
+// Dialect owns everything that differs between SQL engines: identifier quoting,
+// placeholder style and limit, LIMIT/OFFSET syntax and how generated ids are read back.
+// Builders always emit `?` placeholders, Rebind rewrites them right before execution.
+type Dialect interface {
+	Name() string
+	Quote(ident string) string
+	Placeholder(n int) string
+	Rebind(query string) string
+	LimitOffset(limit, offset int) string
+	InsertId() InsertIdStrategy
+	MaxPlaceholders() int
+	// Upsert renders the conflict clause of an INSERT, every column of inc is incremented by a `?` argument.
+	// Without replace and inc the conflicting row is kept as is. key is the generated key column, empty without one.
+	Upsert(table string, target []string, replace []string, inc []string, key string) (string, error)
+	// ArrayAppend renders col with a `?` argument appended, on a postgres array or on a JSON array.
+	ArrayAppend(col string) string
+	Violation(err error) *Violation
+}
+
+var (
+	MySQL    Dialect = &mysqlDialect{}
+	Postgres Dialect = &postgresDialect{}
+	SQLite   Dialect = &sqliteDialect{}
+)
+
+func dialectOf(config ...*DefaultConfigQuery) Dialect {
+	if len(config) > 0 && config[0] != nil && config[0].Dialect != nil {
+		return config[0].Dialect
+	}
+	return MySQL
+}
+
+// quoteCol quotes a `table`.`col` pair, table can be empty.
+func quoteCol(d Dialect, table string, col string) string {
+	if table == "" {
+		return d.Quote(col)
+	}
+	return d.Quote(table) + "." + d.Quote(col)
+}
+
+type mysqlDialect struct{}
+
+func (m *mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (m *mysqlDialect) Quote(ident string) string {
+	return "`" + strings.ReplaceAll(ident, "`", "``") + "`"
+}
+
+func (m *mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (m *mysqlDialect) Rebind(query string) string {
+	return query
+}
+
+func (m *mysqlDialect) LimitOffset(limit, offset int) string {
+	if limit <= 0 && offset <= 0 {
+		return ""
+	}
+	if limit <= 0 {
+		// mysql has no OFFSET without LIMIT, use the biggest row count instead
+		return fmt.Sprintf("LIMIT 18446744073709551615 OFFSET %d", offset)
+	}
+	if offset <= 0 {
+		return fmt.Sprintf("LIMIT %d", limit)
+	}
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (m *mysqlDialect) InsertId() InsertIdStrategy {
+	return LastInsertId
+}
+
+func (m *mysqlDialect) MaxPlaceholders() int {
+	return 65535
+}
+
+// Upsert ignores target, mysql updates the row conflicting on any unique key. The key is set by LAST_INSERT_ID
+// so the id of the updated or kept row is read back like the one of an inserted row.
+func (m *mysqlDialect) Upsert(table string, target []string, replace []string, inc []string, key string) (string, error) {
+	sets := []string{}
+	for _, col := range replace {
+		sets = append(sets, fmt.Sprintf("%v = VALUES(%v)", m.Quote(col), m.Quote(col)))
+	}
+	for _, col := range inc {
+		sets = append(sets, fmt.Sprintf("%v = %v + ?", m.Quote(col), m.Quote(col)))
+	}
+	switch {
+	case key != "":
+		// also the no-op update keeping the row, unlike INSERT IGNORE it doesn't hide the other errors
+		sets = append(sets, fmt.Sprintf("%v = LAST_INSERT_ID(%v)", m.Quote(key), m.Quote(key)))
+	case len(sets) == 0 && len(target) > 0:
+		sets = append(sets, fmt.Sprintf("%v = %v", m.Quote(target[0]), m.Quote(target[0])))
+	case len(sets) == 0:
+		return "", fmt.Errorf("repo: keeping the conflicting row of %v needs a generated key or a Target", table)
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "), nil
+}
+
+func (m *mysqlDialect) ArrayAppend(col string) string {
+	return "JSON_ARRAY_APPEND(" + m.Quote(col) + ", '$', ?)"
+}
+
+func (m *mysqlDialect) Violation(err error) *Violation {
+	return mysqlViolation(err)
+}
+
+type postgresDialect struct{}
+
+func (p *postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (p *postgresDialect) Quote(ident string) string {
+	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+}
+
+func (p *postgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (p *postgresDialect) Rebind(query string) string {
+	return rebindNumbered(query, p.Placeholder)
+}
+
+func (p *postgresDialect) LimitOffset(limit, offset int) string {
+	q := ""
+	if limit > 0 {
+		q += fmt.Sprintf("LIMIT %d", limit)
+	}
+	if offset > 0 {
+		if q != "" {
+			q += " "
+		}
+		q += fmt.Sprintf("OFFSET %d", offset)
+	}
+	return q
+}
+
+func (p *postgresDialect) InsertId() InsertIdStrategy {
+	return Returning
+}
+
+func (p *postgresDialect) MaxPlaceholders() int {
+	return 65535
+}
+
+func (p *postgresDialect) Upsert(table string, target []string, replace []string, inc []string, key string) (string, error) {
+	return onConflict(p, table, target, replace, inc)
+}
+
+func (p *postgresDialect) ArrayAppend(col string) string {
+	return "array_append(" + p.Quote(col) + ", ?)"
+}
+
+func (p *postgresDialect) Violation(err error) *Violation {
+	return postgresViolation(err)
+}
+
+type sqliteDialect struct{}
+
+func (s *sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (s *sqliteDialect) Quote(ident string) string {
+	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+}
+
+func (s *sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (s *sqliteDialect) Rebind(query string) string {
+	return query
+}
+
+func (s *sqliteDialect) LimitOffset(limit, offset int) string {
+	if limit <= 0 && offset <= 0 {
+		return ""
+	}
+	if limit <= 0 {
+		return fmt.Sprintf("LIMIT -1 OFFSET %d", offset)
+	}
+	if offset <= 0 {
+		return fmt.Sprintf("LIMIT %d", limit)
+	}
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (s *sqliteDialect) InsertId() InsertIdStrategy {
+	return LastInsertId
+}
+
+// SQLITE_MAX_VARIABLE_NUMBER since sqlite 3.32
+func (s *sqliteDialect) MaxPlaceholders() int {
+	return 32766
+}
+
+func (s *sqliteDialect) Upsert(table string, target []string, replace []string, inc []string, key string) (string, error) {
+	return onConflict(s, table, target, replace, inc)
+}
+
+func (s *sqliteDialect) ArrayAppend(col string) string {
+	return "json_insert(" + s.Quote(col) + ", '$[#]', ?)"
+}
+
+func (s *sqliteDialect) Violation(err error) *Violation {
+	return sqliteViolation(err)
+}
+
+// onConflict renders the ON CONFLICT clause shared by postgres and sqlite, without target any conflict keeps
+// the existing row and an update is an error.
+func onConflict(d Dialect, table string, target []string, replace []string, inc []string) (string, error) {
+	cols := make([]string, len(target))
+	for i, col := range target {
+		cols[i] = d.Quote(col)
+	}
+	clause := "ON CONFLICT "
+	if len(cols) > 0 {
+		clause += fmt.Sprintf("(%v) ", strings.Join(cols, ", "))
+	}
+	sets := []string{}
+	for _, col := range replace {
+		sets = append(sets, fmt.Sprintf("%v = EXCLUDED.%v", d.Quote(col), d.Quote(col)))
+	}
+	for _, col := range inc {
+		sets = append(sets, fmt.Sprintf("%v = %v + ?", d.Quote(col), quoteCol(d, table, col)))
+	}
+	if len(sets) == 0 {
+		return clause + "DO NOTHING", nil
+	}
+	if len(cols) == 0 {
+		return "", fmt.Errorf("repo: updating the conflicting row of %v needs a Target", table)
+	}
+	return clause + "DO UPDATE SET " + strings.Join(sets, ", "), nil
+}
+
+// rebindNumbered replaces every `?` outside of quotes by the numbered placeholder of the dialect.
+func rebindNumbered(query string, placeholder func(n int) string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			b.WriteByte(c)
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+			b.WriteByte(c)
+		case '?':
+			n++
+			b.WriteString(placeholder(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
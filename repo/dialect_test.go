@@ -0,0 +1,46 @@
+package repo
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	query := "SELECT * FROM \"accounts\" WHERE \"accounts\".\"Name\" = ? AND \"accounts\".\"Note\" = '?' AND \"accounts\".\"Age\" > ?"
+	got := Postgres.Rebind(query)
+	want := "SELECT * FROM \"accounts\" WHERE \"accounts\".\"Name\" = $1 AND \"accounts\".\"Note\" = '?' AND \"accounts\".\"Age\" > $2"
+	if got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if MySQL.Rebind(query) != query {
+		t.Fatalf("mysql must keep ? placeholders")
+	}
+}
+
+func TestDialectQuery(t *testing.T) {
+	for _, tc := range []struct {
+		dialect Dialect
+		want    string
+	}{
+		{MySQL, "SELECT `accounts`.`Name` FROM `accounts` WHERE `accounts`.`Id` = ? ORDER BY `accounts`.`Name` ASC"},
+		{Postgres, "SELECT \"accounts\".\"Name\" FROM \"accounts\" WHERE \"accounts\".\"Id\" = ? ORDER BY \"accounts\".\"Name\" ASC"},
+	} {
+		r := NewRepoDB(nil, tc.dialect)
+		q, args := r.GetById(&Account{}).Select(Col("Name", "accounts")).Where(P("Id", "accounts", Equal, 1)).OrderBy(Col("Name", "accounts"), ASC).Query()
+		if q != " "+tc.want {
+			t.Fatalf("%v: got %q, want %q", tc.dialect.Name(), q, tc.want)
+		}
+		if len(args) != 1 || args[0] != 1 {
+			t.Fatalf("%v: unexpected args %v", tc.dialect.Name(), args)
+		}
+	}
+}
+
+func TestLimitOffset(t *testing.T) {
+	if got := MySQL.LimitOffset(0, 20); got != "LIMIT 18446744073709551615 OFFSET 20" {
+		t.Fatalf("mysql offset only: %v", got)
+	}
+	if got := Postgres.LimitOffset(0, 20); got != "OFFSET 20" {
+		t.Fatalf("postgres offset only: %v", got)
+	}
+	if got := SQLite.LimitOffset(10, 20); got != "LIMIT 10 OFFSET 20" {
+		t.Fatalf("sqlite: %v", got)
+	}
+}
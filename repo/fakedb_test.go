@@ -0,0 +1,198 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// fakeResult is what the fake driver answers to one statement.
+type fakeResult struct {
+	columns      []string
+	rows         [][]driver.Value
+	lastInsertId int64
+	rowsAffected int64
+	err          error
+}
+
+// fakeDB records the statements it receives and answers them with handler.
+type fakeDB struct {
+	mu      sync.Mutex
+	queries []string
+	args    [][]interface{}
+	handler func(query string, args []interface{}) *fakeResult
+}
+
+func (f *fakeDB) run(query string, args []driver.NamedValue) *fakeResult {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	f.mu.Lock()
+	f.queries = append(f.queries, query)
+	f.args = append(f.args, values)
+	f.mu.Unlock()
+	if f.handler == nil {
+		return &fakeResult{rowsAffected: 1, lastInsertId: 1}
+	}
+	if res := f.handler(query, values); res != nil {
+		return res
+	}
+	return &fakeResult{rowsAffected: 1, lastInsertId: 1}
+}
+
+func (f *fakeDB) Queries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.queries...)
+}
+
+var fakeSeq int64
+
+// newFakeRepo opens a Repo on a new fake database.
+func newFakeRepo(dialect Dialect, handler func(query string, args []interface{}) *fakeResult) (*Repo, *fakeDB) {
+	f := &fakeDB{handler: handler}
+	name := fmt.Sprintf("fake%d", atomic.AddInt64(&fakeSeq, 1))
+	sql.Register(name, &fakeDriver{db: f})
+	r, err := Open(name, "", dialect)
+	if err != nil {
+		panic(err)
+	}
+	return r, f
+}
+
+type fakeDriver struct {
+	db *fakeDB
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{db: d.db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if res := c.db.run("BEGIN", nil); res.err != nil {
+		return nil, res.err
+	}
+	return &fakeTx{db: c.db}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res := c.db.run(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res := c.db.run(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &fakeRows{res: res}, nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (t *fakeTx) Commit() error {
+	return t.db.run("COMMIT", nil).err
+}
+
+func (t *fakeTx) Rollback() error {
+	return t.db.run("ROLLBACK", nil).err
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, driver.ErrSkip
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	res := s.db.run(s.query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res, nil
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	res := s.db.run(s.query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &fakeRows{res: res}, nil
+}
+
+func (r *fakeResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r *fakeResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type fakeRows struct {
+	res *fakeResult
+	i   int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.res.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.res.rows) {
+		return io.EOF
+	}
+	copy(dest, r.res.rows[r.i])
+	r.i++
+	return nil
+}
+
+// rowsOf builds a result with the columns of the header line "Id,Name" and rows of values.
+func rowsOf(header string, rows ...[]driver.Value) *fakeResult {
+	return &fakeResult{columns: strings.Split(header, ","), rows: rows}
+}
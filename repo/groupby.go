@@ -0,0 +1,45 @@
+package repo
+
+import "strings"
+
+type groupBy struct {
+	cols []*C
+}
+
+func (g *groupBy) query(config ...*DefaultConfigQuery) (string, []interface{}) {
+	d := dialectOf(config...)
+	cols := make([]string, len(g.cols))
+	for i, col := range g.cols {
+		table := col.table
+		if len(config) > 0 && config[0].RenameTableAs != "" && table != "" {
+			table = config[0].RenameTableAs
+		}
+		cols[i] = col.render(d, table)
+	}
+	return "GROUP BY " + strings.Join(cols, ", "), nil
+}
+
+func (g *groupBy) Append(querier Querier) Querier {
+	if other, ok := querier.(*groupBy); ok {
+		g.cols = append(g.cols, other.cols...)
+	}
+	return g
+}
+
+// GroupBy groups the rows by cols, several calls add columns.
+func (q *QueryBuilder) GroupBy(cols ...*C) *QueryBuilder {
+	if q.groupBy == nil {
+		q.groupBy = &groupBy{}
+	}
+	q.groupBy.Append(&groupBy{cols: cols})
+	return q
+}
+
+// Having filters the groups, use PCol to compare an aggregate. Several calls must all match.
+func (q *QueryBuilder) Having(expr Expr) *QueryBuilder {
+	if q.having == nil {
+		q.having = &Where{keyword: "HAVING"}
+	}
+	q.having.(*Where).exprs = append(q.having.(*Where).exprs, expr)
+	return q
+}
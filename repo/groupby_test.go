@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+type PostStats struct {
+	AccountId uint32
+	Posts     int64
+	Views     int64
+}
+
+func TestGroupByHavingLimit(t *testing.T) {
+	r, db := newFakeRepo(Postgres, func(query string, args []interface{}) *fakeResult {
+		return rowsOf("AccountId,Posts,Views",
+			[]driver.Value{int64(7), int64(3), int64(120)},
+			[]driver.Value{int64(8), int64(3), int64(40)},
+		)
+	})
+	defer r.Close()
+	qb := From[Post](r).
+		Select(Col("AccountId", "posts")).
+		Select(Count(nil).As("Posts")).
+		Select(Sum(Col("Views", "posts")).As("Views")).
+		Where(P("Views", "posts", Greater, 0)).
+		GroupBy(Col("AccountId", "posts")).
+		Having(PCol(Count(nil), GreaterEqual, 2)).
+		Having(PCol(Max(Col("Views", "posts")), Less, 1000)).
+		OrderBy(Col("AccountId", "posts"), ASC).
+		Limit(10).
+		Offset(20)
+	stats, err := All[PostStats](context.Background(), r, qb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ` SELECT "posts"."AccountId", COUNT(*) AS "Posts", SUM("posts"."Views") AS "Views" FROM "posts" WHERE "posts"."Views" > $1` +
+		` GROUP BY "posts"."AccountId" HAVING COUNT(*) >= $2 AND MAX("posts"."Views") < $3 ORDER BY "posts"."AccountId" ASC LIMIT 10 OFFSET 20`
+	if got := db.Queries()[0]; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(db.args[0], []interface{}{int64(0), int64(2), int64(1000)}) {
+		t.Fatalf("unexpected args %v", db.args[0])
+	}
+	if len(stats) != 2 || stats[0].AccountId != 7 || stats[1].Views != 40 {
+		t.Fatalf("rows without id must all be kept: %+v", stats)
+	}
+	if query, _ := From[Post](r).Limit(5).Limit(0).Query(); query != `FROM "posts"` {
+		t.Fatalf("Limit(0) must remove the limit: %q", query)
+	}
+}
+
+func TestCloneGroupByHaving(t *testing.T) {
+	r := NewRepoDB(nil, MySQL)
+	qb := From[Post](r).GroupBy(Col("AccountId", "posts")).Having(PCol(Count(nil), GreaterEqual, 2))
+	want, _ := qb.Query()
+	c := qb.clone().GroupBy(Col("Views", "posts")).Having(PCol(Count(nil), Less, 9))
+	if got, _ := qb.Query(); got != want {
+		t.Fatalf("the clone changed the builder: got %q, want %q", got, want)
+	}
+	if got, args := c.Query(); got == want || len(args) != 2 {
+		t.Fatalf("the clone didn't keep its own clauses: %q %v", got, args)
+	}
+}
+
+func TestHavingRaw(t *testing.T) {
+	r := NewRepoDB(nil, Postgres)
+	query, args := From[Post](r).
+		Select(Col("AccountId", "posts")).
+		GroupBy(Col("AccountId", "posts")).
+		Having(PCol(Raw(`SUM("posts"."Views") / COUNT(*)`), Greater, 10)).
+		Query()
+	want := ` SELECT "posts"."AccountId" FROM "posts" GROUP BY "posts"."AccountId" HAVING SUM("posts"."Views") / COUNT(*) > ?`
+	if query != want || len(args) != 1 {
+		t.Fatalf("got %q %v, want %q", query, args, want)
+	}
+	group := &groupBy{cols: []*C{Col("AccountId", "posts"), Col("Views", "")}}
+	if got, _ := group.query(&DefaultConfigQuery{Dialect: Postgres, RenameTableAs: "p"}); got != `GROUP BY "p"."AccountId", "Views"` {
+		t.Fatalf("an unqualified column must stay unqualified: %q", got)
+	}
+}
@@ -0,0 +1,282 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+// InsertAllOptions changes how InsertAll splits the rows and what it reads back.
+type InsertAllOptions struct {
+	// Returning reads back the generated ids, they are set on the primary keys of the changesets too.
+	// The primary key must be generated by the database.
+	Returning bool
+	// MaxRows limits the rows of one statement, 0 only keeps under the placeholder and byte limits.
+	MaxRows int
+	// MaxBytes is the estimated size limit of one statement (max_allowed_packet of MySQL), 4MB when 0.
+	MaxBytes int
+	// OnConflict turns the inserts into upserts, it can't be used with Returning.
+	OnConflict *OnConflict
+}
+
+const defaultMaxBytes = 4 << 20
+
+// insertBatch is a group of changesets casting the same fields, inserted by one statement.
+type insertBatch struct {
+	fields []string
+	rows   []*changeset.ChangeSet
+}
+
+// InsertAll inserts rows into the table of schema with multi-row INSERT statements.
+// rows is a []*changeset.ChangeSet or a []map[string]interface{} cast by changeset.CastValues.
+// Rows casting different fields go to different statements, every row is validated before the first one runs.
+// When more than one statement is needed they run in a transaction.
+// It returns the number of inserted rows and, with Returning, their ids in the order of rows.
+// With OnConflict the count is the rows affected of the driver: mysql counts 2 for an updated row
+// and 0 for a kept one, postgres and sqlite count the inserted and updated rows.
+// The associations of the changesets aren't inserted, a changeset with associations fails with ErrAssocs.
+func (r *Repo) InsertAll(ctx context.Context, schema interface{}, rows interface{}, opts ...*InsertAllOptions) (int64, []int64, error) {
+	opt := &InsertAllOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+	if opt.Returning && opt.OnConflict != nil {
+		// the rows kept or updated on conflict can't be matched with the returned ids
+		return 0, nil, fmt.Errorf("repo: InsertAll can't return ids of an upsert")
+	}
+	css, err := insertAllChangeSets(schema, rows)
+	if err != nil {
+		return 0, nil, err
+	}
+	if opt.Returning && len(css) > 0 && generatedKey(css[0]) == nil {
+		return 0, nil, fmt.Errorf("repo: InsertAll can't return ids, the primary key of %v isn't generated", css[0].ReflectSchema.Type().Name())
+	}
+	for _, cs := range css {
+		if len(cs.Assocs) > 0 {
+			return 0, nil, ErrAssocs
+		}
+		if err := validInsert(cs); err != nil {
+			return 0, nil, err
+		}
+		if len(cs.CastedBoxes) == 0 {
+			return 0, nil, fmt.Errorf("repo: InsertAll got a row without any cast field")
+		}
+	}
+	batches := r.insertBatches(css, opt)
+	var inserted int64
+	ids := map[*changeset.ChangeSet]int64{}
+	run := func(tx *Repo) error {
+		for _, batch := range batches {
+			n, err := tx.insertBatch(ctx, batch, opt, ids)
+			if err != nil {
+				return err
+			}
+			inserted += n
+		}
+		return nil
+	}
+	if len(batches) > 1 {
+		err = r.Transaction(ctx, nil, run)
+	} else {
+		err = run(r)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if !opt.Returning {
+		return inserted, nil, nil
+	}
+	returned := make([]int64, len(css))
+	for i, cs := range css {
+		returned[i] = ids[cs]
+	}
+	return inserted, returned, nil
+}
+
+func insertAllChangeSets(schema interface{}, rows interface{}) ([]*changeset.ChangeSet, error) {
+	typ := reflect.Indirect(reflect.ValueOf(schema)).Type()
+	switch rows := rows.(type) {
+	case []*changeset.ChangeSet:
+		for _, cs := range rows {
+			if cs.ReflectSchema.Type() != typ {
+				return nil, fmt.Errorf("repo: InsertAll of %v got a changeset of %v", typ.Name(), cs.ReflectSchema.Type().Name())
+			}
+		}
+		return rows, nil
+	case []map[string]interface{}:
+		css := make([]*changeset.ChangeSet, len(rows))
+		for i, row := range rows {
+			css[i] = changeset.CastValues(reflect.New(typ).Interface(), row)
+		}
+		return css, nil
+	}
+	return nil, fmt.Errorf("repo: InsertAll can't insert rows of type %T", rows)
+}
+
+// insertBatches groups the changesets by casted fields then splits the groups under the limits of the dialect.
+func (r *Repo) insertBatches(css []*changeset.ChangeSet, opt *InsertAllOptions) []*insertBatch {
+	maxBytes := opt.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	groups := map[string]*insertBatch{}
+	order := []string{}
+	for _, cs := range css {
+		fields := append([]string{}, cs.CastedBoxes...)
+		sort.Strings(fields)
+		key := strings.Join(fields, ",")
+		if _, ok := groups[key]; !ok {
+			groups[key] = &insertBatch{fields: fields}
+			order = append(order, key)
+		}
+		groups[key].rows = append(groups[key].rows, cs)
+	}
+	batches := []*insertBatch{}
+	for _, key := range order {
+		group := groups[key]
+		maxRows := r.dialect.MaxPlaceholders() / len(group.fields)
+		if opt.MaxRows > 0 && opt.MaxRows < maxRows {
+			maxRows = opt.MaxRows
+		}
+		batch := &insertBatch{fields: group.fields}
+		size := 0
+		for _, cs := range group.rows {
+			rowSize := rowBytes(cs, group.fields)
+			if len(batch.rows) > 0 && (len(batch.rows) >= maxRows || size+rowSize > maxBytes) {
+				batches = append(batches, batch)
+				batch, size = &insertBatch{fields: group.fields}, 0
+			}
+			batch.rows = append(batch.rows, cs)
+			size += rowSize
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// rowBytes estimates the bytes a row takes in a statement, placeholders and separators included.
+func rowBytes(cs *changeset.ChangeSet, fields []string) int {
+	size := 4
+	for _, field := range fields {
+		switch v := cs.Boxes[field].GetVal().(type) {
+		case string:
+			size += len(v)
+		case []byte:
+			size += len(v)
+		default:
+			size += 8
+		}
+		size += 4
+	}
+	return size
+}
+
+func (r *Repo) insertAllQuery(batch *insertBatch, opt *InsertAllOptions) (string, []interface{}, error) {
+	d := r.dialect
+	cs := batch.rows[0]
+	cols := make([]string, len(batch.fields))
+	for i, field := range batch.fields {
+		cols[i] = d.Quote(cs.Column(field))
+	}
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ") + ")"
+	values := make([]string, len(batch.rows))
+	args := make([]interface{}, 0, len(batch.rows)*len(cols))
+	for i, cs := range batch.rows {
+		values[i] = row
+		for _, field := range batch.fields {
+			args = append(args, cs.Boxes[field].GetVal())
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %v (%v) VALUES %v", d.Quote(cs.TableName()), strings.Join(cols, ", "), strings.Join(values, ", "))
+	if opt.OnConflict != nil {
+		clause, clauseArgs, err := r.conflictClause(opt.OnConflict, cs, batch.fields)
+		if err != nil {
+			return "", nil, err
+		}
+		query += " " + clause
+		args = append(args, clauseArgs...)
+	}
+	if opt.Returning && d.InsertId() == Returning {
+		query += " RETURNING " + d.Quote(generatedKey(cs).Column)
+	}
+	return d.Rebind(query), args, nil
+}
+
+// insertBatch runs the INSERT of batch, with returning the generated ids are stored in ids and set on the primary keys.
+func (r *Repo) insertBatch(ctx context.Context, batch *insertBatch, opt *InsertAllOptions, ids map[*changeset.ChangeSet]int64) (affected int64, err error) {
+	returning := opt.Returning
+	query, args, err := r.insertAllQuery(batch, opt)
+	if err != nil {
+		return 0, err
+	}
+	event := &QueryEvent{Query: query, Args: args}
+	defer func(start time.Time) {
+		event.RowsAffected, event.Err = affected, err
+		r.logQuery(ctx, start, batch.rows[0], event)
+	}(time.Now())
+	generated := []int64{}
+	if returning && r.dialect.InsertId() == Returning {
+		rows, err := r.conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			return 0, r.constraintError(batch.rows[0], err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return 0, err
+			}
+			generated = append(generated, id)
+		}
+		if err := rows.Err(); err != nil {
+			return 0, r.constraintError(batch.rows[0], err)
+		}
+		affected = int64(len(generated))
+	} else {
+		result, err := r.conn.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, r.constraintError(batch.rows[0], err)
+		}
+		if affected, err = result.RowsAffected(); err != nil {
+			return 0, err
+		}
+		if returning {
+			last, err := result.LastInsertId()
+			if err != nil {
+				return 0, err
+			}
+			generated = batchIds(r.dialect, last, len(batch.rows))
+		}
+	}
+	if !returning {
+		return affected, nil
+	}
+	if len(generated) != len(batch.rows) {
+		return affected, fmt.Errorf("repo: %v ids returned for %v rows", len(generated), len(batch.rows))
+	}
+	key := generatedKey(batch.rows[0])
+	for i, cs := range batch.rows {
+		ids[cs] = generated[i]
+		setKey(cs, key, generated[i])
+	}
+	return affected, nil
+}
+
+// batchIds derives the ids of a multi-row insert from LastInsertId, the auto increment ids of one
+// statement are consecutive. MySQL returns the id of the first row, sqlite the id of the last one.
+func batchIds(d Dialect, last int64, n int) []int64 {
+	first := last
+	if d.Name() == "sqlite" {
+		first = last - int64(n) + 1
+	}
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = first + int64(i)
+	}
+	return ids
+}
@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+func TestInsertAllChunks(t *testing.T) {
+	nextId := int64(100)
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		first := nextId
+		nextId += int64(len(args) / 2)
+		return &fakeResult{rowsAffected: int64(len(args) / 2), lastInsertId: first}
+	})
+	defer r.Close()
+	rows := []map[string]interface{}{}
+	for _, title := range []string{"a", "b", "c", "d", "e"} {
+		rows = append(rows, map[string]interface{}{"Title": title, "Meta": map[string]interface{}{"tags": []string{title}}})
+	}
+	n, ids, err := r.InsertAll(context.Background(), &Post{}, rows, &InsertAllOptions{Returning: true, MaxRows: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 || !reflect.DeepEqual(ids, []int64{100, 101, 102, 103, 104}) {
+		t.Fatalf("got %v rows, ids %v", n, ids)
+	}
+	want := []string{
+		"BEGIN",
+		"INSERT INTO `posts` (`Meta`, `Title`) VALUES (?, ?), (?, ?)",
+		"INSERT INTO `posts` (`Meta`, `Title`) VALUES (?, ?), (?, ?)",
+		"INSERT INTO `posts` (`Meta`, `Title`) VALUES (?, ?)",
+		"COMMIT",
+	}
+	if got := db.Queries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if meta := db.args[1][0]; string(meta.([]byte)) != `{"tags":["a"]}` || db.args[1][1] != "a" {
+		t.Fatalf("unexpected args %v", db.args[1])
+	}
+}
+
+func TestInsertAllReturning(t *testing.T) {
+	r, db := newFakeRepo(Postgres, func(query string, args []interface{}) *fakeResult {
+		return rowsOf("Id", []driver.Value{int64(1)}, []driver.Value{int64(2)})
+	})
+	defer r.Close()
+	css := []*changeset.ChangeSet{newAccount("ann"), newAccount("bob")}
+	_, ids, err := r.InsertAll(context.Background(), &Account{}, css, &InsertAllOptions{Returning: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `INSERT INTO "accounts" ("Email", "Name") VALUES ($1, $2), ($3, $4) RETURNING "Id"`
+	if got := db.Queries(); len(got) != 1 || got[0] != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2}) || css[1].ReflectSchema.FieldByName("Id").Uint() != 2 {
+		t.Fatalf("ids not read back: %v", ids)
+	}
+}
+
+func TestInsertAllLimits(t *testing.T) {
+	r, db := newFakeRepo(SQLite, nil)
+	defer r.Close()
+	css := []*changeset.ChangeSet{newAccount("ann"), changeset.CastClass(&Account{}, &AccountMsg{AccountName: "bob"})}
+	if _, _, err := r.InsertAll(context.Background(), &Account{}, css); !errors.Is(err, changeset.ErrInvalid) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if len(db.Queries()) != 0 {
+		t.Fatalf("no statement must run when a row is invalid")
+	}
+	css = make([]*changeset.ChangeSet, 40000)
+	for i := range css {
+		css[i] = newAccount("ann")
+	}
+	batches := r.insertBatches(css, &InsertAllOptions{})
+	if len(batches) != 3 || len(batches[0].rows) != SQLite.MaxPlaceholders()/2 {
+		t.Fatalf("placeholder limit not kept: %v batches", len(batches))
+	}
+	batches = r.insertBatches(css[:100], &InsertAllOptions{MaxBytes: 1024})
+	if len(batches) < 2 || rowBytes(css[0], batches[0].fields)*len(batches[0].rows) > 1024 {
+		t.Fatalf("byte limit not kept")
+	}
+	if ids := batchIds(SQLite, 10, 3); !reflect.DeepEqual(ids, []int64{8, 9, 10}) {
+		t.Fatalf("sqlite ids %v", ids)
+	}
+}
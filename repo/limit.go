@@ -0,0 +1,33 @@
+package repo
+
+type limitOffset struct {
+	limit  int
+	offset int
+}
+
+func (l *limitOffset) query(config ...*DefaultConfigQuery) (string, []interface{}) {
+	return dialectOf(config...).LimitOffset(l.limit, l.offset), nil
+}
+
+func (l *limitOffset) Append(querier Querier) Querier {
+	return l
+}
+
+func (q *QueryBuilder) limitOffset() *limitOffset {
+	if q.limit == nil {
+		q.limit = &limitOffset{}
+	}
+	return q.limit.(*limitOffset)
+}
+
+// Limit keeps the first n rows, n <= 0 removes the limit.
+func (q *QueryBuilder) Limit(n int) *QueryBuilder {
+	q.limitOffset().limit = n
+	return q
+}
+
+// Offset skips the first n rows.
+func (q *QueryBuilder) Offset(n int) *QueryBuilder {
+	q.limitOffset().offset = n
+	return q
+}
@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+// QueryEvent describes one statement run by a Repo.
+// RowsAffected counts the rows changed by an exec or the rows returned by a select.
+type QueryEvent struct {
+	Query        string
+	Args         []interface{}
+	Duration     time.Duration
+	RowsAffected int64
+	Err          error
+	Schema       string
+}
+
+// Logger receives an event after every statement of a Repo, it is called from the goroutine running the statement.
+type Logger interface {
+	LogQuery(ctx context.Context, event *QueryEvent)
+}
+
+// LoggerFunc is a function used as a Logger.
+type LoggerFunc func(ctx context.Context, event *QueryEvent)
+
+func (f LoggerFunc) LogQuery(ctx context.Context, event *QueryEvent) {
+	f(ctx, event)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) LogQuery(ctx context.Context, event *QueryEvent) {}
+
+// NopLogger drops every event, it is the Logger of a Repo opened without WithLogger.
+var NopLogger Logger = nopLogger{}
+
+// WithLogger sends the events of the Repo to l.
+func WithLogger(l Logger) Option {
+	return func(r *Repo) {
+		if l == nil {
+			l = NopLogger
+		}
+		r.logger = l
+	}
+}
+
+// logQuery fills the duration since start and the schema name then sends event to the logger.
+func (r *Repo) logQuery(ctx context.Context, start time.Time, schema interface{}, event *QueryEvent) {
+	event.Duration = time.Since(start)
+	event.Schema = schemaName(schema)
+	r.logger.LogQuery(ctx, event)
+}
+
+func schemaName(schema interface{}) string {
+	if cs, ok := schema.(*changeset.ChangeSet); ok {
+		return cs.ReflectSchema.Type().Name()
+	}
+	if schema == nil {
+		return ""
+	}
+	t, ok := schema.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(schema)
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
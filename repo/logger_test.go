@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+func TestLoggerEvents(t *testing.T) {
+	failed := errors.New("connection reset")
+	r, _ := newFakeRepo(Postgres, func(query string, args []interface{}) *fakeResult {
+		switch query[:6] {
+		case "INSERT":
+			return rowsOf("Id", []driver.Value{int64(7)})
+		case "UPDATE":
+			return &fakeResult{err: failed}
+		}
+		return rowsOf("Id,Name,Email", []driver.Value{int64(7), "john", "a@b.c"})
+	})
+	defer r.Close()
+	events := []*QueryEvent{}
+	WithLogger(LoggerFunc(func(ctx context.Context, event *QueryEvent) {
+		events = append(events, event)
+	}))(r)
+	ctx := context.Background()
+	cs := changeset.CastClass(&Account{}, &AccountMsg{AccountName: "john", AccountEmail: "a@b.c"})
+	if err := r.Save(ctx, cs); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UpdateById(ctx, cs); !errors.Is(err, failed) {
+		t.Fatalf("expected the driver error, got %v", err)
+	}
+	if _, err := All[Account](ctx, r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %v events", len(events))
+	}
+	insert := events[0]
+	if insert.Query != `INSERT INTO "accounts" ("Name", "Email") VALUES ($1, $2) RETURNING "Id"` || len(insert.Args) != 2 ||
+		insert.RowsAffected != 1 || insert.Err != nil || insert.Schema != "Account" || insert.Duration <= 0 {
+		t.Fatalf("unexpected insert event %+v", insert)
+	}
+	if events[1].Err != failed || events[1].Schema != "Account" {
+		t.Fatalf("unexpected update event %+v", events[1])
+	}
+	if events[2].RowsAffected != 1 || events[2].Schema != "Account" {
+		t.Fatalf("unexpected select event %+v", events[2])
+	}
+}
@@ -0,0 +1,151 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+// Multi is a list of named operations run in order in one transaction by Repo.ExecMulti, like Ecto.Multi.
+type Multi struct {
+	steps []*multiStep
+}
+
+type multiStep struct {
+	name string
+	cs   *changeset.ChangeSet
+	// run returns the changeset of the step, a function step builds a new one on every run
+	run func(ctx context.Context, tx *Repo, results map[string]interface{}) (*changeset.ChangeSet, error)
+}
+
+// MultiError tells which step of a Multi failed, the transaction was rolled back.
+// ChangeSet is the changeset of the step, nil for a Run step. Results holds the steps done before it.
+type MultiError struct {
+	Step      string
+	Err       error
+	ChangeSet *changeset.ChangeSet
+	Results   map[string]interface{}
+}
+
+func (e *MultiError) Error() string {
+	return fmt.Sprintf("repo: multi step %v: %v", e.Step, e.Err)
+}
+
+func (e *MultiError) Unwrap() error {
+	return e.Err
+}
+
+func NewMulti() *Multi {
+	return &Multi{}
+}
+
+// Insert saves cs with its associations, the result of the step is the inserted schema with its id.
+func (m *Multi) Insert(name string, cs *changeset.ChangeSet) *Multi {
+	return m.change(name, cs, nil, insertStep)
+}
+
+// InsertFn is Insert with the changeset built by fn when the step runs, fn reads the results
+// of the previous steps, like the id of an earlier insert.
+func (m *Multi) InsertFn(name string, fn func(results map[string]interface{}) *changeset.ChangeSet) *Multi {
+	return m.change(name, nil, fn, insertStep)
+}
+
+// Update updates the row of cs by its id, the result of the step is the updated schema.
+func (m *Multi) Update(name string, cs *changeset.ChangeSet) *Multi {
+	return m.change(name, cs, nil, (*Repo).UpdateById)
+}
+
+// UpdateFn is Update with the changeset built by fn from the results of the previous steps.
+func (m *Multi) UpdateFn(name string, fn func(results map[string]interface{}) *changeset.ChangeSet) *Multi {
+	return m.change(name, nil, fn, (*Repo).UpdateById)
+}
+
+// Delete deletes the row of a schema or of a changeset, the result of the step is the deleted schema.
+func (m *Multi) Delete(name string, schemaOrChangeset interface{}) *Multi {
+	return m.change(name, toChangeSet(schemaOrChangeset), nil, deleteStep)
+}
+
+// DeleteFn is Delete with the changeset built by fn from the results of the previous steps.
+func (m *Multi) DeleteFn(name string, fn func(results map[string]interface{}) *changeset.ChangeSet) *Multi {
+	return m.change(name, nil, fn, deleteStep)
+}
+
+func insertStep(tx *Repo, ctx context.Context, cs *changeset.ChangeSet) error {
+	return tx.Save(ctx, cs)
+}
+
+func deleteStep(tx *Repo, ctx context.Context, cs *changeset.ChangeSet) error {
+	return tx.Delete(ctx, cs)
+}
+
+// Run adds a step running fn on the transaction, fn reads the results of the previous steps
+// and the value it returns is the result of the step.
+func (m *Multi) Run(name string, fn func(ctx context.Context, tx *Repo, results map[string]interface{}) (interface{}, error)) *Multi {
+	m.steps = append(m.steps, &multiStep{name: name, run: func(ctx context.Context, tx *Repo, results map[string]interface{}) (*changeset.ChangeSet, error) {
+		value, err := fn(ctx, tx, results)
+		if err != nil {
+			return nil, err
+		}
+		results[name] = value
+		return nil, nil
+	}})
+	return m
+}
+
+// change adds a step running op on cs, or on the changeset built by build when the step runs.
+func (m *Multi) change(name string, cs *changeset.ChangeSet, build func(results map[string]interface{}) *changeset.ChangeSet,
+	op func(tx *Repo, ctx context.Context, cs *changeset.ChangeSet) error) *Multi {
+	run := func(ctx context.Context, tx *Repo, results map[string]interface{}) (*changeset.ChangeSet, error) {
+		current := cs
+		if build != nil {
+			if current = build(results); current == nil {
+				return nil, fmt.Errorf("no changeset to run")
+			}
+		}
+		if err := op(tx, ctx, current); err != nil {
+			return current, err
+		}
+		results[name] = schemaOf(current)
+		return current, nil
+	}
+	m.steps = append(m.steps, &multiStep{name: name, cs: cs, run: run})
+	return m
+}
+
+// ExecMulti runs the steps of m in one transaction and returns their results by step name.
+// The first failing step rolls the transaction back and is returned as a *MultiError.
+func (r *Repo) ExecMulti(ctx context.Context, m *Multi, opts ...*sql.TxOptions) (map[string]interface{}, error) {
+	names := map[string]bool{}
+	for _, step := range m.steps {
+		if names[step.name] {
+			return nil, &MultiError{Step: step.name, Err: fmt.Errorf("duplicate step name"), ChangeSet: step.cs}
+		}
+		names[step.name] = true
+	}
+	var txOpts *sql.TxOptions
+	if len(opts) > 0 {
+		txOpts = opts[0]
+	}
+	results := map[string]interface{}{}
+	err := r.Transaction(ctx, txOpts, func(tx *Repo) error {
+		for _, step := range m.steps {
+			if cs, err := step.run(ctx, tx, results); err != nil {
+				return &MultiError{Step: step.name, Err: err, ChangeSet: cs, Results: results}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func schemaOf(cs *changeset.ChangeSet) interface{} {
+	if cs.ReflectSchema.CanAddr() {
+		return cs.ReflectSchema.Addr().Interface()
+	}
+	return cs.ReflectSchema.Interface()
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+func TestExecMulti(t *testing.T) {
+	nextId := int64(0)
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		nextId++
+		return &fakeResult{rowsAffected: 1, lastInsertId: nextId}
+	})
+	defer r.Close()
+	ctx := context.Background()
+	m := NewMulti().
+		Insert("owner", newAccount("ann")).
+		Run("audit", func(ctx context.Context, tx *Repo, results map[string]interface{}) (interface{}, error) {
+			owner := results["owner"].(*Account)
+			cs := newAccount("audit")
+			if err := tx.Save(ctx, cs); err != nil {
+				return nil, err
+			}
+			return owner.Id, nil
+		}).
+		Delete("old", &Account{Id: 9})
+	results, err := r.ExecMulti(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results["owner"].(*Account).Id == 0 || results["audit"] != results["owner"].(*Account).Id || results["old"].(*Account).Id != 9 {
+		t.Fatalf("unexpected results %v", results)
+	}
+	want := []string{"BEGIN", "INSERT", "INSERT", "DELETE", "COMMIT"}
+	if got := statements(db); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestExecMultiFailingStep(t *testing.T) {
+	r, db := newFakeRepo(MySQL, nil)
+	defer r.Close()
+	invalid := changeset.CastClass(&Account{}, &AccountMsg{AccountName: "bob"})
+	m := NewMulti().
+		Insert("owner", newAccount("ann")).
+		Insert("member", invalid).
+		Run("never", func(ctx context.Context, tx *Repo, results map[string]interface{}) (interface{}, error) {
+			t.Fatalf("a step after the failure ran")
+			return nil, nil
+		})
+	results, err := r.ExecMulti(context.Background(), m)
+	var multiErr *MultiError
+	if results != nil || !errors.As(err, &multiErr) {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	if multiErr.Step != "member" || multiErr.ChangeSet != invalid || !errors.Is(err, changeset.ErrInvalid) {
+		t.Fatalf("unexpected error %+v", multiErr)
+	}
+	if _, ok := multiErr.Results["owner"]; !ok {
+		t.Fatalf("results of the done steps missing")
+	}
+	want := []string{"BEGIN", "INSERT", "ROLLBACK"}
+	if got := statements(db); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	_, err = r.ExecMulti(context.Background(), NewMulti().Delete("a", &Account{Id: 1}).Delete("a", &Account{Id: 2}))
+	if !errors.As(err, &multiErr) || multiErr.Step != "a" {
+		t.Fatalf("duplicate step name not rejected: %v", err)
+	}
+}
+
+func TestExecMultiFn(t *testing.T) {
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		return &fakeResult{rowsAffected: 1, lastInsertId: 5}
+	})
+	defer r.Close()
+	m := NewMulti().
+		Insert("writer", changeset.CastValues(&Writer{}, map[string]interface{}{"Name": "ann"})).
+		Run("bio", func(ctx context.Context, tx *Repo, results map[string]interface{}) (interface{}, error) {
+			return "go", nil
+		}).
+		InsertFn("profile", func(results map[string]interface{}) *changeset.ChangeSet {
+			writer := results["writer"].(*Writer)
+			return changeset.CastValues(&Profile{}, map[string]interface{}{"Bio": results["bio"], "WriterId": writer.Id})
+		})
+	results, err := r.ExecMulti(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := results["profile"].(*Profile)
+	if profile.WriterId != 5 || profile.Id != 5 || profile.Bio != "go" {
+		t.Fatalf("results not chained: %+v", profile)
+	}
+	if args := db.args[len(db.args)-2]; !reflect.DeepEqual(args, []interface{}{"go", int64(5)}) {
+		t.Fatalf("unexpected insert args %v", args)
+	}
+	again, err := r.ExecMulti(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again["profile"].(*Profile) == profile {
+		t.Fatalf("a second run must build a new changeset")
+	}
+
+	var multiErr *MultiError
+	m = NewMulti().UpdateFn("none", func(results map[string]interface{}) *changeset.ChangeSet { return nil })
+	if _, err = r.ExecMulti(context.Background(), m); !errors.As(err, &multiErr) || multiErr.Step != "none" {
+		t.Fatalf("a step without changeset must fail, got %v", err)
+	}
+}
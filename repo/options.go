@@ -0,0 +1,30 @@
+package repo
+
+import "time"
+
+// Option configures a Repo when it is created.
+type Option func(r *Repo)
+
+func MaxOpenConns(n int) Option {
+	return func(r *Repo) {
+		r.db.SetMaxOpenConns(n)
+	}
+}
+
+func MaxIdleConns(n int) Option {
+	return func(r *Repo) {
+		r.db.SetMaxIdleConns(n)
+	}
+}
+
+func ConnMaxLifetime(d time.Duration) Option {
+	return func(r *Repo) {
+		r.db.SetConnMaxLifetime(d)
+	}
+}
+
+func ConnMaxIdleTime(d time.Duration) Option {
+	return func(r *Repo) {
+		r.db.SetConnMaxIdleTime(d)
+	}
+}
@@ -1,6 +1,6 @@
 package repo
 
-import "fmt"
+import "strings"
 
 type OrderType uint8
 const (
@@ -8,27 +8,82 @@ const (
 	ASC
 )
 
-type orderBy struct {
-	name string
-	table string
+// Nulls places the NULL values of an ordering term, MySQL has no NULLS FIRST/LAST so it is emulated with IS NULL.
+type Nulls uint8
+const (
+	NullsFirst Nulls = iota + 1
+	NullsLast
+)
+
+type orderTerm struct {
+	col       *C
 	orderType OrderType
+	nulls     Nulls
+}
+
+// orderBy lists the ordering terms in the order they were added.
+type orderBy struct {
+	terms []*orderTerm
 }
 
 
 func (o *orderBy) query(config ...*DefaultConfigQuery) (string, []interface{}){
-	if len(config) > 0 {
-		o.table = config[0].RenameTableAs
+	d := dialectOf(config...)
+	terms := []string{}
+	for _, term := range o.terms {
+		table := term.col.table
+		if len(config) > 0 && config[0].RenameTableAs != "" && table != "" {
+			table = config[0].RenameTableAs
+		}
+		col := term.col.render(d, table)
+		direction := ""
+		if term.orderType == DESC {
+			direction = " DESC"
+		}
+		if term.orderType == ASC {
+			direction = " ASC"
+		}
+		switch {
+		case term.nulls != 0 && d.Name() == "mysql":
+			// false sorts before true, so IS NULL puts the nulls last and IS NULL DESC first
+			if term.nulls == NullsFirst {
+				terms = append(terms, col+" IS NULL DESC")
+			} else {
+				terms = append(terms, col+" IS NULL")
+			}
+			terms = append(terms, col+direction)
+		case term.nulls == NullsFirst:
+			terms = append(terms, col+direction+" NULLS FIRST")
+		case term.nulls == NullsLast:
+			terms = append(terms, col+direction+" NULLS LAST")
+		default:
+			terms = append(terms, col+direction)
+		}
 	}
-	q := fmt.Sprintf("ORDER BY `%v`.`%v`", o.table, o.name)
-	if o.orderType == DESC {
-		q += " DESC"
+	if len(terms) == 0 {
+		return "", nil
 	}
-	if o.orderType == ASC {
-		q += " ASC"
-	}
-	return q, nil
+	return "ORDER BY " + strings.Join(terms, ", "), nil
 }
 
 func (o *orderBy) Append(querier Querier) Querier {
+	if other, ok := querier.(*orderBy); ok {
+		o.terms = append(o.terms, other.terms...)
+	}
 	return o
 }
+
+// OrderBy adds an ordering term, the terms apply in the order they were added.
+// c is a column, an alias made with Alias, an aggregate or a Raw expression. A column of a preloaded
+// relation orders the relation rows inside each result.
+func (q *QueryBuilder) OrderBy(c *C, orderType OrderType, nulls ...Nulls) *QueryBuilder {
+	term := &orderTerm{col: c, orderType: orderType}
+	if len(nulls) > 0 {
+		term.nulls = nulls[0]
+	}
+	if q.orderBy == nil {
+		q.orderBy = &orderBy{}
+	}
+	q.orderBy.Append(&orderBy{terms: []*orderTerm{term}})
+	return q
+}
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+)
+
+func TestOrderByTerms(t *testing.T) {
+	for _, tc := range []struct {
+		dialect Dialect
+		want    string
+	}{
+		{Postgres, `FROM "posts" ORDER BY "posts"."Views" DESC NULLS LAST, "Total" ASC, length(Title) DESC, MAX("posts"."Id") ASC NULLS FIRST`},
+		{MySQL, "FROM `posts` ORDER BY `posts`.`Views` IS NULL, `posts`.`Views` DESC, `Total` ASC, length(Title) DESC, MAX(`posts`.`Id`) IS NULL DESC, MAX(`posts`.`Id`) ASC"},
+	} {
+		qb := From[Post](NewRepoDB(nil, tc.dialect)).
+			OrderBy(Col("Views", "posts"), DESC, NullsLast).
+			OrderBy(Alias("Total"), ASC).
+			OrderBy(Raw("length(Title)"), DESC).
+			OrderBy(Max(Col("Id", "posts")), ASC, NullsFirst)
+		if query, _ := qb.Query(); query != tc.want {
+			t.Fatalf("%v: got %q, want %q", tc.dialect.Name(), query, tc.want)
+		}
+	}
+}
+
+func TestOrderedPreload(t *testing.T) {
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		return rowsOf("Id,author_name,Posts$Id,Posts$Title",
+			[]driver.Value{int64(2), "bob", int64(12), "a"},
+			[]driver.Value{int64(1), "ann", int64(11), "b"},
+			[]driver.Value{int64(2), "bob", int64(10), "c"},
+		)
+	})
+	defer r.Close()
+	qb := r.GetById(&Author{}, func() (interface{}, string, string, bool) {
+		return &Post{}, "AccountId", "Id", false
+	}).
+		Select(Col("Id", "authors")).
+		Select(Col("author_name", "authors")).
+		Select(Col("Id", "posts").As("Posts$Id")).
+		Select(Col("Title", "posts").As("Posts$Title")).
+		OrderBy(Col("author_name", "authors"), DESC).
+		OrderBy(Col("Title", "posts"), ASC)
+	authors, err := All[Author](context.Background(), r, qb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := " SELECT `authors`.`Id`, `authors`.`author_name`, `posts`.`Id` AS `Posts$Id`, `posts`.`Title` AS `Posts$Title`" +
+		" FROM `authors` INNER JOIN `posts` ON `authors`.`Id` = `posts`.`AccountId` ORDER BY `authors`.`author_name` DESC, `posts`.`Title` ASC"
+	if got := db.Queries()[0]; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if len(authors) != 2 || authors[0].Name != "bob" || authors[1].Name != "ann" {
+		t.Fatalf("results must follow the rows: %+v", authors)
+	}
+	if posts := authors[0].Posts; len(posts) != 2 || posts[0].Title != "a" || posts[1].Title != "c" {
+		t.Fatalf("relation rows must follow the rows: %+v", posts)
+	}
+}
@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+// Pagination is one page of PageOf with the totals of the whole query.
+type Pagination[T any] struct {
+	Items        []*T
+	Page         int
+	PageSize     int
+	TotalEntries int64
+	TotalPages   int
+}
+
+// Page reads the page-th page (from 1) of pageSize rows with LIMIT and OFFSET, PageOf runs it with the totals.
+func (q *QueryBuilder) Page(page int, pageSize int) *QueryBuilder {
+	if page < 1 {
+		page = 1
+	}
+	q.page, q.pageSize = page, pageSize
+	return q.Limit(pageSize).Offset((page - 1) * pageSize)
+}
+
+// CountQuery counts the rows of the builder, without its projection, ordering and limit.
+// A grouped query is counted through a subquery, a query joining preloads counts the distinct primary keys.
+func (q *QueryBuilder) CountQuery() (string, []interface{}) {
+	d := q.dialect
+	if d == nil {
+		d = MySQL
+	}
+	counted := q.clone()
+	counted.orderBy, counted.limit = nil, nil
+	if counted.groupBy != nil || counted.having != nil {
+		if counted.Projection == nil {
+			counted.Select(Raw("1"))
+		}
+		query, args := counted.Query()
+		return fmt.Sprintf("SELECT COUNT(*) FROM (%v) AS counted", strings.TrimSpace(query)), args
+	}
+	count := Count(nil)
+	if counted.joined && counted.schema != nil {
+		if pk := changeset.MetaOf(counted.schema).PK; pk != nil {
+			count = Raw(fmt.Sprintf("COUNT(DISTINCT %v)", quoteCol(d, counted.table, pk.Column)))
+		}
+	}
+	counted.Projection = &Selector{cols: []*C{count}}
+	return counted.Query()
+}
+
+// PageOf reads the page set with QueryBuilder.Page and counts the rows of the whole query.
+// qb can't have joins, LIMIT and OFFSET would page the joined rows instead of the rows of T.
+// Both queries run in one transaction so the totals match the items, a read only repeatable read
+// transaction is opened unless r is already in one.
+func PageOf[T any](ctx context.Context, r *Repo, qb *QueryBuilder) (*Pagination[T], error) {
+	if qb == nil || qb.pageSize <= 0 {
+		return nil, fmt.Errorf("repo: PageOf needs a builder with a Page of a positive size")
+	}
+	if qb.joined {
+		return nil, fmt.Errorf("repo: PageOf can't page a query with joins")
+	}
+	page := &Pagination[T]{Page: qb.page, PageSize: qb.pageSize}
+	read := func(tx *Repo) error {
+		items, err := All[T](ctx, tx, qb)
+		if err != nil {
+			return err
+		}
+		page.Items = items
+		page.TotalEntries, err = tx.count(ctx, qb)
+		return err
+	}
+	var err error
+	if r.tx != nil {
+		err = read(r)
+	} else {
+		err = r.Transaction(ctx, snapshotOptions(r.dialect), read)
+	}
+	if err != nil {
+		return nil, err
+	}
+	page.TotalPages = int((page.TotalEntries + int64(page.PageSize) - 1) / int64(page.PageSize))
+	return page, nil
+}
+
+func (r *Repo) count(ctx context.Context, qb *QueryBuilder) (total int64, err error) {
+	query, args := qb.CountQuery()
+	query = r.dialect.Rebind(query)
+	defer func(start time.Time) {
+		r.logQuery(ctx, start, qb.schema, &QueryEvent{Query: query, Args: args, Err: err})
+	}(time.Now())
+	err = r.conn.QueryRowContext(ctx, query, args...).Scan(&total)
+	return total, err
+}
+
+// snapshotOptions is a read only transaction seeing one snapshot, sqlite transactions always do.
+func snapshotOptions(d Dialect) *sql.TxOptions {
+	if d.Name() == "sqlite" {
+		return &sql.TxOptions{ReadOnly: true}
+	}
+	return &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+}
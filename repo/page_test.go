@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPageOf(t *testing.T) {
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		if strings.Contains(query, "COUNT") {
+			return rowsOf("count", []driver.Value{int64(41)})
+		}
+		return rowsOf("Id,Name,Email", []driver.Value{int64(21), "ann", "ann@mail.com"})
+	})
+	defer r.Close()
+	var count *QueryEvent
+	WithLogger(LoggerFunc(func(ctx context.Context, event *QueryEvent) {
+		if strings.Contains(event.Query, "COUNT") {
+			count = event
+		}
+	}))(r)
+	qb := From[Account](r).Where(P("Name", "accounts", Like, "a%")).OrderBy(Col("Name", "accounts"), ASC).Page(3, 10)
+	page, err := PageOf[Account](context.Background(), r, qb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Page != 3 || page.TotalEntries != 41 || page.TotalPages != 5 {
+		t.Fatalf("unexpected page %+v", page)
+	}
+	want := []string{
+		"BEGIN",
+		" SELECT `accounts`.`Email`, `accounts`.`Id`, `accounts`.`Name` FROM `accounts` WHERE `accounts`.`Name` LIKE ? ORDER BY `accounts`.`Name` ASC LIMIT 10 OFFSET 20",
+		" SELECT COUNT(*) FROM `accounts` WHERE `accounts`.`Name` LIKE ?",
+		"COMMIT",
+	}
+	if got := db.Queries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if count == nil || count.RowsAffected != 0 {
+		t.Fatalf("a count changes no row: %+v", count)
+	}
+	if _, err := PageOf[Book](context.Background(), r, From[Book](r).Join("Writer").Page(1, 10)); err == nil {
+		t.Fatalf("a query with joins must be rejected")
+	}
+	r.Transaction(context.Background(), nil, func(tx *Repo) error {
+		_, err := PageOf[Account](context.Background(), tx, qb)
+		return err
+	})
+	if got := db.Queries(); len(got) != 8 || got[5] != want[1] || got[6] != want[2] {
+		t.Fatalf("PageOf must run in the transaction of the repo: %q", got[4:])
+	}
+}
+
+func TestCountQuery(t *testing.T) {
+	r := NewRepoDB(nil, Postgres)
+	grouped := From[Post](r).
+		Select(Col("AccountId", "posts")).
+		Select(Count(nil).As("Posts")).
+		GroupBy(Col("AccountId", "posts")).
+		Having(PCol(Count(nil), Greater, 1)).
+		OrderBy(Alias("Posts"), DESC).
+		Page(2, 5)
+	query, args := grouped.CountQuery()
+	want := `SELECT COUNT(*) FROM (SELECT "posts"."AccountId", COUNT(*) AS "Posts" FROM "posts" GROUP BY "posts"."AccountId" HAVING COUNT(*) > ?) AS counted`
+	if query != want || len(args) != 1 {
+		t.Fatalf("got %q %v, want %q", query, args, want)
+	}
+	joined := r.GetById(&Account{}, func() (interface{}, string, string, bool) {
+		return &Post{}, "AccountId", "Id", false
+	})
+	want = ` SELECT COUNT(DISTINCT "accounts"."Id") FROM "accounts" INNER JOIN "posts" ON "accounts"."Id" = "posts"."AccountId"`
+	if query, _ := joined.CountQuery(); query != want {
+		t.Fatalf("got %q, want %q", query, want)
+	}
+}
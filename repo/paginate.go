@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+var ErrInvalidCursor = errors.New("repo: invalid pagination cursor")
+
+// CursorPage is one page of Paginate, Next and Prev are empty when there is no page in that direction.
+type CursorPage[T any] struct {
+	Items []*T
+	Next  string
+	Prev  string
+}
+
+// cursor is the position of a row in the ordering, Before reads the rows preceding it.
+type cursor struct {
+	Before bool              `json:"b,omitempty"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// Paginate reads limit rows of qb after (or before) cursor, seeking on the OrderBy columns instead of using OFFSET.
+// The primary key is added to the ordering so every row has one position. The ordering columns must be
+// non-null columns of T, the cursors are only valid for the same ordering. An empty cursor reads the first page.
+// qb can't have joins, the LIMIT would count the joined rows instead of the rows of T.
+func Paginate[T any](ctx context.Context, r *Repo, qb *QueryBuilder, cur string, limit int) (*CursorPage[T], error) {
+	var schema T
+	meta := changeset.MetaOf(&schema)
+	if qb == nil {
+		qb = From[T](r)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("repo: Paginate needs a positive limit")
+	}
+	if qb.joined {
+		return nil, fmt.Errorf("repo: Paginate can't page a query with joins")
+	}
+	qb = qb.clone()
+	terms, fields, err := seekTerms(qb, meta)
+	if err != nil {
+		return nil, err
+	}
+	position, values := &cursor{}, []interface{}{}
+	if cur != "" {
+		if position, values, err = decodeCursor(cur, fields); err != nil {
+			return nil, err
+		}
+	}
+	ordering := &orderBy{}
+	for _, term := range terms {
+		flipped := *term
+		if position.Before {
+			// read backward then put the rows back in order
+			flipped.orderType = flip(term.orderType)
+		}
+		ordering.terms = append(ordering.terms, &flipped)
+	}
+	qb.orderBy = ordering
+	if cur != "" {
+		qb.Where(seek(ordering.terms, values))
+	}
+	qb.Limit(limit + 1).Offset(0)
+	items, err := All[T](ctx, r, qb)
+	if err != nil {
+		return nil, err
+	}
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	if position.Before {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	page := &CursorPage[T]{Items: items}
+	if len(items) == 0 {
+		return page, nil
+	}
+	first, last := reflect.ValueOf(items[0]).Elem(), reflect.ValueOf(items[len(items)-1]).Elem()
+	if (position.Before && more) || (!position.Before && cur != "") {
+		page.Prev = encodeCursor(true, first, fields)
+	}
+	if (!position.Before && more) || position.Before {
+		page.Next = encodeCursor(false, last, fields)
+	}
+	return page, nil
+}
+
+// seekTerms returns the ordering terms of qb ending with the primary key and the fields they read.
+func seekTerms(qb *QueryBuilder, meta *changeset.Meta) ([]*orderTerm, []*changeset.FieldMeta, error) {
+	terms := []*orderTerm{}
+	if o, ok := qb.orderBy.(*orderBy); ok {
+		terms = append(terms, o.terms...)
+	}
+	fields := []*changeset.FieldMeta{}
+	hasPK := false
+	for _, term := range terms {
+		c := term.col
+		f, ok := meta.FieldByColumn(c.name)
+		if c.raw != "" || c.fn != "" || (c.table != "" && c.table != qb.table) || !ok {
+			return nil, nil, fmt.Errorf("repo: can't paginate on %v, it isn't a column of %v", c.render(MySQL, c.table), meta.Type.Name())
+		}
+		fields = append(fields, f)
+		hasPK = hasPK || f == meta.PK
+	}
+	if meta.PK == nil {
+		return nil, nil, fmt.Errorf("repo: %v has no primary key to paginate on", meta.Type.Name())
+	}
+	if !hasPK {
+		orderType := ASC
+		if len(terms) > 0 && terms[len(terms)-1].orderType == DESC {
+			orderType = DESC
+		}
+		terms = append(terms, &orderTerm{col: Col(meta.PK.Column, qb.table), orderType: orderType})
+		fields = append(fields, meta.PK)
+	}
+	return terms, fields, nil
+}
+
+// seek matches the rows after values in the ordering of terms:
+// (a > va) OR (a = va AND b > vb) OR ...
+func seek(terms []*orderTerm, values []interface{}) Expr {
+	alternatives := []Expr{}
+	for i, term := range terms {
+		equal := []Expr{}
+		for j := 0; j < i; j++ {
+			equal = append(equal, P(terms[j].col.name, terms[j].col.table, Equal, values[j]))
+		}
+		op := Greater
+		if term.orderType == DESC {
+			op = Less
+		}
+		alternatives = append(alternatives, And(append(equal, P(term.col.name, term.col.table, op, values[i]))...))
+	}
+	return Or(alternatives...)
+}
+
+func flip(orderType OrderType) OrderType {
+	if orderType == DESC {
+		return ASC
+	}
+	return DESC
+}
+
+func encodeCursor(before bool, row reflect.Value, fields []*changeset.FieldMeta) string {
+	c := &cursor{Before: before}
+	for _, f := range fields {
+		data, _ := json.Marshal(row.FieldByIndex(f.Index).Interface())
+		c.Values = append(c.Values, data)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes s and its values into the types of the ordering fields.
+func decodeCursor(s string, fields []*changeset.FieldMeta) (*cursor, []interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	c := &cursor{}
+	if err := json.Unmarshal(data, c); err != nil || len(c.Values) != len(fields) {
+		return nil, nil, ErrInvalidCursor
+	}
+	values := make([]interface{}, len(fields))
+	for i, f := range fields {
+		value := reflect.New(f.Type)
+		if err := json.Unmarshal(c.Values[i], value.Interface()); err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		values[i] = value.Elem().Interface()
+	}
+	return c, values, nil
+}
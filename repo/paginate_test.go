@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	var res *fakeResult
+	r, db := newFakeRepo(Postgres, func(query string, args []interface{}) *fakeResult {
+		return res
+	})
+	defer r.Close()
+	ctx := context.Background()
+	qb := From[Account](r).Where(P("Email", "accounts", Like, "%@mail.com")).OrderBy(Col("Name", "accounts"), ASC)
+
+	res = rowsOf("Id,Name,Email",
+		[]driver.Value{int64(1), "ann", "ann@mail.com"},
+		[]driver.Value{int64(2), "bob", "bob@mail.com"},
+		[]driver.Value{int64(3), "bob", "bob2@mail.com"},
+	)
+	page, err := Paginate[Account](ctx, r, qb, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || page.Next == "" || page.Prev != "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	want := ` SELECT "accounts"."Email", "accounts"."Id", "accounts"."Name" FROM "accounts" WHERE "accounts"."Email" LIKE $1` +
+		` ORDER BY "accounts"."Name" ASC, "accounts"."Id" ASC LIMIT 3`
+	if got := db.Queries()[0]; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	res = rowsOf("Id,Name,Email", []driver.Value{int64(3), "bob", "bob2@mail.com"})
+	page, err = Paginate[Account](ctx, r, qb, page.Next, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Next != "" || page.Prev == "" {
+		t.Fatalf("unexpected last page %+v", page)
+	}
+	want = ` SELECT "accounts"."Email", "accounts"."Id", "accounts"."Name" FROM "accounts" WHERE "accounts"."Email" LIKE $1` +
+		` AND ("accounts"."Name" > $2 OR ("accounts"."Name" = $3 AND "accounts"."Id" > $4)) ORDER BY "accounts"."Name" ASC, "accounts"."Id" ASC LIMIT 3`
+	if got := db.Queries()[1]; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(db.args[1], []interface{}{"%@mail.com", "bob", "bob", int64(2)}) {
+		t.Fatalf("unexpected args %v", db.args[1])
+	}
+
+	res = rowsOf("Id,Name,Email",
+		[]driver.Value{int64(2), "bob", "bob@mail.com"},
+		[]driver.Value{int64(1), "ann", "ann@mail.com"},
+	)
+	page, err = Paginate[Account](ctx, r, qb, page.Prev, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || page.Items[0].Id != 1 || page.Prev != "" || page.Next == "" {
+		t.Fatalf("unexpected previous page %+v", page)
+	}
+	want = ` SELECT "accounts"."Email", "accounts"."Id", "accounts"."Name" FROM "accounts" WHERE "accounts"."Email" LIKE $1` +
+		` AND ("accounts"."Name" < $2 OR ("accounts"."Name" = $3 AND "accounts"."Id" < $4)) ORDER BY "accounts"."Name" DESC, "accounts"."Id" DESC LIMIT 3`
+	if got := db.Queries()[2]; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if _, err := Paginate[Account](ctx, r, qb, "not a cursor", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := Paginate[Account](ctx, r, From[Account](r).OrderBy(Raw("random()"), ASC), "", 2); err == nil {
+		t.Fatalf("an expression can't be paginated on")
+	}
+	if _, err := Paginate[Book](ctx, r, From[Book](r).Join("Writer"), "", 2); err == nil {
+		t.Fatalf("a query with joins must be rejected")
+	}
+}
@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Expr is a condition of a WHERE clause: a *Predicate or an And, Or or Not group.
+type Expr interface {
+	// expr renders the condition with its arguments in order, rename replaces the table of the predicates.
+	expr(d Dialect, rename string) (string, []interface{})
+}
+
+type group struct {
+	op    string
+	exprs []Expr
+}
+
+// And matches when every expr matches, it matches everything when empty.
+func And(exprs ...Expr) Expr {
+	return &group{op: "AND", exprs: exprs}
+}
+
+// Or matches when one of exprs matches, it matches nothing when empty.
+func Or(exprs ...Expr) Expr {
+	return &group{op: "OR", exprs: exprs}
+}
+
+type not struct {
+	inner Expr
+}
+
+// Not matches when expr doesn't.
+func Not(expr Expr) Expr {
+	return &not{inner: expr}
+}
+
+func (g *group) expr(d Dialect, rename string) (string, []interface{}) {
+	if len(g.exprs) == 0 {
+		if g.op == "OR" {
+			return "1 = 0", nil
+		}
+		return "1 = 1", nil
+	}
+	parts := make([]string, len(g.exprs))
+	args := []interface{}{}
+	for i, e := range g.exprs {
+		query, exprArgs := e.expr(d, rename)
+		if inner, ok := e.(*group); ok && len(inner.exprs) > 1 {
+			query = "(" + query + ")"
+		}
+		parts[i] = query
+		args = append(args, exprArgs...)
+	}
+	return strings.Join(parts, " "+g.op+" "), args
+}
+
+func (n *not) expr(d Dialect, rename string) (string, []interface{}) {
+	query, args := n.inner.expr(d, rename)
+	return "NOT (" + query + ")", args
+}
+
+func (p *Predicate) expr(d Dialect, rename string) (string, []interface{}) {
+	table := p.table
+	if rename != "" {
+		table = rename
+	}
+	col := (&C{name: p.col, fn: p.fn, raw: p.raw}).render(d, table)
+	switch p.op {
+	case IsNull:
+		return col + " IS NULL", nil
+	case IsNotNull:
+		return col + " IS NOT NULL", nil
+	case In, NotIn:
+		values := expand(p.val)
+		if len(values) == 0 {
+			// IN () isn't valid SQL
+			if p.op == In {
+				return "1 = 0", nil
+			}
+			return "1 = 1", nil
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		if p.op == In {
+			return fmt.Sprintf("%v IN (%v)", col, placeholders), values
+		}
+		return fmt.Sprintf("%v NOT IN (%v)", col, placeholders), values
+	case Between:
+		bounds := expand(p.val)
+		if len(bounds) != 2 {
+			// the bounds are missing, match nothing rather than everything
+			return "1 = 0", nil
+		}
+		return col + " BETWEEN ? AND ?", bounds
+	}
+	value, args := "?", []interface{}{p.val}
+	if c, ok := p.val.(*C); ok {
+		value, args = c.render(d, c.table), nil
+	}
+	if p.op == ILike && d.Name() != "postgres" {
+		return fmt.Sprintf("LOWER(%v) LIKE LOWER(%v)", col, value), args
+	}
+	return fmt.Sprintf("%v %v %v", col, p.op.toString(), value), args
+}
+
+// expand returns the elements of a slice value, any other value is a slice of one element.
+func expand(val interface{}) []interface{} {
+	rv := reflect.ValueOf(val)
+	if !rv.IsValid() {
+		return nil
+	}
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return []interface{}{val}
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPredicateTree(t *testing.T) {
+	r := NewRepoDB(nil, Postgres)
+	qb := From[Post](r).
+		Where(Or(
+			And(P("Title", "posts", ILike, "%go%"), Not(P("Body", "posts", NotLike, "%draft%"))),
+			P("AccountId", "posts", In, []uint32{1, 2, 3}),
+		)).
+		Where(P("Meta", "posts", IsNotNull, nil)).
+		Where(P("Views", "posts", Between, []int{10, 20})).
+		Where(P("Views", "posts", Greater, Col("AccountId", "posts"))).
+		Where(P("Id", "posts", NotEqual, 9))
+	query, args := qb.Query()
+	want := `FROM "posts" WHERE (("posts"."Title" ILIKE ? AND NOT ("posts"."Body" NOT LIKE ?)) OR "posts"."AccountId" IN (?, ?, ?))` +
+		` AND "posts"."Meta" IS NOT NULL AND "posts"."Views" BETWEEN ? AND ? AND "posts"."Views" > "posts"."AccountId" AND "posts"."Id" <> ?`
+	if query != want {
+		t.Fatalf("got %q, want %q", query, want)
+	}
+	wantArgs := []interface{}{"%go%", "%draft%", uint32(1), uint32(2), uint32(3), 10, 20, 9}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("got %v, want %v", args, wantArgs)
+	}
+}
+
+func TestPredicateEdgeCases(t *testing.T) {
+	for _, tc := range []struct {
+		expr Expr
+		want string
+		args int
+	}{
+		{P("Id", "posts", In, []int{}), "1 = 0", 0},
+		{P("Id", "posts", NotIn, nil), "1 = 1", 0},
+		{P("Id", "posts", In, 4), "`posts`.`Id` IN (?)", 1},
+		{P("Meta", "posts", In, [][]byte{[]byte("a")}), "`posts`.`Meta` IN (?)", 1},
+		{P("Views", "posts", Between, []int{1}), "1 = 0", 0},
+		{P("Title", "posts", ILike, "go%"), "LOWER(`posts`.`Title`) LIKE LOWER(?)", 1},
+		{P("Title", "posts", IsNull, nil), "`posts`.`Title` IS NULL", 0},
+		{Or(), "1 = 0", 0},
+		{And(P("Id", "posts", Equal, 1)), "`posts`.`Id` = ?", 1},
+	} {
+		query, args := tc.expr.expr(MySQL, "")
+		if query != tc.want || len(args) != tc.args {
+			t.Fatalf("got %q %v, want %q", query, args, tc.want)
+		}
+	}
+}
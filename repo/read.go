@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+var (
+	ErrNoRows          = errors.New("repo: no rows in result set")
+	ErrMultipleResults = errors.New("repo: expected at most one result")
+)
+
+// From starts a QueryBuilder on the table of T.
+func From[T any](r *Repo) *QueryBuilder {
+	var schema T
+	return r.GetById(&schema)
+}
+
+// All runs qb and scans every row into a T, a builder without Select selects the columns of T.
+// The associations given to qb.Preload are loaded into the results.
+// A nil qb selects the whole table.
+func All[T any](ctx context.Context, r *Repo, qb *QueryBuilder) ([]*T, error) {
+	var schema T
+	if qb == nil {
+		qb = From[T](r)
+	}
+	results, err := r.all(ctx, qb, &schema)
+	if err != nil {
+		return nil, err
+	}
+	typed := make([]*T, len(results))
+	for i, result := range results {
+		typed[i] = result.(*T)
+	}
+	return typed, nil
+}
+
+// One is All expecting at most one row, it returns ErrNoRows or ErrMultipleResults otherwise.
+func One[T any](ctx context.Context, r *Repo, qb *QueryBuilder) (*T, error) {
+	results, err := All[T](ctx, r, qb)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrNoRows
+	}
+	if len(results) > 1 {
+		return nil, ErrMultipleResults
+	}
+	return results[0], nil
+}
+
+// Get fetches a T by its primary key.
+func Get[T any](ctx context.Context, r *Repo, id any) (*T, error) {
+	var schema T
+	pk := changeset.MetaOf(&schema).PK
+	if pk == nil {
+		return nil, fmt.Errorf("repo: %T has no primary key", schema)
+	}
+	qb := From[T](r)
+	return One[T](ctx, r, qb.Where(P(pk.Column, qb.table, Equal, id)))
+}
+
+// GetBy fetches a T matching every field = value of clauses.
+func GetBy[T any](ctx context.Context, r *Repo, clauses map[string]any) (*T, error) {
+	var schema T
+	meta := changeset.MetaOf(&schema)
+	fields := make([]string, 0, len(clauses))
+	for field := range clauses {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	qb := From[T](r)
+	for _, field := range fields {
+		col := field
+		if f, ok := meta.Field(field); ok {
+			col = f.Column
+		}
+		qb.Where(P(col, qb.table, Equal, clauses[field]))
+	}
+	return One[T](ctx, r, qb)
+}
+
+// query runs a select and scans its rows into cast.
+func (r *Repo) query(ctx context.Context, query string, args []interface{}, cast interface{}) (results []interface{}, err error) {
+	query = r.dialect.Rebind(query)
+	event := &QueryEvent{Query: query, Args: args}
+	defer func(start time.Time) {
+		event.RowsAffected, event.Err = int64(len(results)), err
+		r.logQuery(ctx, start, cast, event)
+	}(time.Now())
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return r.ParseToStruct(rows, cast)
+}
@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	r, db := newFakeRepo(Postgres, func(query string, args []interface{}) *fakeResult {
+		return rowsOf("Id,Name,Email",
+			[]driver.Value{int64(2), "bob", "bob@mail.com"},
+			[]driver.Value{int64(1), "ann", "ann@mail.com"},
+		)
+	})
+	defer r.Close()
+	qb := From[Account](r).Where(P("Name", "accounts", Like, "%o%"))
+	accounts, err := All[Account](context.Background(), r, qb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 || accounts[0].Name != "bob" || accounts[1].Id != 1 {
+		t.Fatalf("unexpected accounts %+v", accounts)
+	}
+	want := ` SELECT "accounts"."Email", "accounts"."Id", "accounts"."Name" FROM "accounts" WHERE "accounts"."Name" LIKE $1`
+	if got := db.Queries()[0]; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if qb.Projection != nil {
+		t.Fatalf("All must not change the builder")
+	}
+}
+
+func TestOneErrors(t *testing.T) {
+	count := 0
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		rows := [][]driver.Value{}
+		for i := 0; i < count; i++ {
+			rows = append(rows, []driver.Value{int64(i + 1), "ann", "ann@mail.com"})
+		}
+		return rowsOf("Id,Name,Email", rows...)
+	})
+	defer r.Close()
+	ctx := context.Background()
+	if _, err := Get[Account](ctx, r, 1); !errors.Is(err, ErrNoRows) {
+		t.Fatalf("expected ErrNoRows, got %v", err)
+	}
+	count = 2
+	if _, err := GetBy[Account](ctx, r, map[string]any{"Name": "ann", "Email": "ann@mail.com"}); !errors.Is(err, ErrMultipleResults) {
+		t.Fatalf("expected ErrMultipleResults, got %v", err)
+	}
+	count = 1
+	account, err := Get[Account](ctx, r, 1)
+	if err != nil || account.Name != "ann" {
+		t.Fatalf("unexpected %v %v", account, err)
+	}
+	want := " SELECT `accounts`.`Email`, `accounts`.`Id`, `accounts`.`Name` FROM `accounts` WHERE `accounts`.`Email` = ? AND `accounts`.`Name` = ?"
+	if got := db.Queries()[1]; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+type Country struct {
+	Code string `ecto:"pk"`
+	Name string
+}
+
+func (c *Country) TableName() string {
+	return "countries"
+}
+
+func TestAllWithoutId(t *testing.T) {
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		if len(args) > 0 {
+			return rowsOf("Code,Name", []driver.Value{"fr", "France"})
+		}
+		return rowsOf("Code,Name", []driver.Value{"fr", "France"}, []driver.Value{"de", "Germany"})
+	})
+	defer r.Close()
+	countries, err := All[Country](context.Background(), r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(countries) != 2 || countries[0].Code != "fr" || countries[1].Name != "Germany" {
+		t.Fatalf("rows of a schema without Id must all be kept, got %+v", countries)
+	}
+	country, err := Get[Country](context.Background(), r, "fr")
+	if err != nil || country.Name != "France" {
+		t.Fatalf("unexpected %+v %v", country, err)
+	}
+	want := " SELECT `countries`.`Code`, `countries`.`Name` FROM `countries` WHERE `countries`.`Code` = ?"
+	if got := db.Queries()[1]; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
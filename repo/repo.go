@@ -3,36 +3,74 @@ package repo
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"github.com/go-sql-driver/mysql"
 	"github.com/DSA-JSC/GoEcto/changeset"
 	"reflect"
-	"strings"
+	"time"
 )
 
 type DefaultConfigQuery struct {
 	IncludeColAs bool
 	RenameTableAs string
+	Dialect Dialect
 }
 
-var repo *Repo
+// Repo runs the queries on its pool, or on its transaction for the Repo given by Transaction.
 type Repo struct {
 	db *sql.DB
+	conn executor
+	tx *sql.Tx
+	savepoints int
+	dialect Dialect
+	logger Logger
 }
 
-func NewRepo(config *mysql.Config) *Repo {
-	if repo != nil {
-		return repo
-	}
-	db, err := sql.Open("mysql", config.FormatDSN())
+// NewRepo opens a new MySQL pool, every call returns an independent Repo.
+func NewRepo(config *mysql.Config, opts ...Option) (*Repo, error) {
+	return Open("mysql", config.FormatDSN(), MySQL, opts...)
+}
+
+// Open opens a pool with any registered database/sql driver, the dialect must match the driver.
+func Open(driverName string, dsn string, dialect Dialect, opts ...Option) (*Repo, error) {
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+	return NewRepoDB(db, dialect, opts...), nil
+}
+
+// NewRepoDB wraps an opened *sql.DB, the dialect must match the driver used to open it.
+func NewRepoDB(db *sql.DB, dialect Dialect, opts ...Option) *Repo {
+	if dialect == nil {
+		dialect = MySQL
+	}
+	r := &Repo{
+		db: db,
+		conn: db,
+		dialect: dialect,
+		logger: NopLogger,
 	}
-	repo = &Repo{
-		db :db,
+	for _, opt := range opts {
+		opt(r)
 	}
-	return repo
+	return r
+}
+
+func (r *Repo) Dialect() Dialect {
+	return r.dialect
+}
+
+func (r *Repo) DB() *sql.DB {
+	return r.db
+}
+
+// Close closes the pool, it returns ErrCloseTx on the Repo of a transaction since the pool is shared.
+func (r *Repo) Close() error {
+	if r.tx != nil {
+		return ErrCloseTx
+	}
+	return r.db.Close()
 }
 
 type Querier interface{
@@ -44,6 +82,8 @@ type C struct {
 	name string
 	table string
 	as string
+	fn string
+	raw string
 }
 
 func Col(name string, tb string) *C {
@@ -74,19 +114,19 @@ func (s *Selector) query(config ...*DefaultConfigQuery) (string, []interface{})
 		return "", nil
 	}
 
+	d := dialectOf(config...)
 	q := ""
 	for i, col := range s.cols {
 		var tempRename string = col.table
-		if len(config) > 0 {
+		if len(config) > 0 && config[0].RenameTableAs != "" {
 			tempRename = config[0].RenameTableAs
 			if !config[0].IncludeColAs {
 				col.as = ""
 			}
 		}
-		q += fmt.Sprintf("`%v`.`%v`", tempRename, col.name)
+		q += col.render(d, tempRename)
 		if col.as != "" {
-			q += " AS "
-			q += col.as
+			q += " AS " + d.Quote(col.as)
 		}
 		if i < len(s.cols) - 1 {
 			q += ", "
@@ -104,48 +144,65 @@ type QueryBuilder struct {
 	Predicate  Querier
 	limit      Querier
 	groupBy    Querier
+	having     Querier
 	orderBy    Querier
 	args       []interface{}
-}
-func (q *QueryBuilder) OrderBy(c *C, orderType OrderType) *QueryBuilder {
-	o := &orderBy{
-		name: c.name,
-		table: c.table,
-		orderType: orderType,
-	}
-	q.orderBy = o
-	return q
+	dialect    Dialect
+	schema     reflect.Type
+	joined     bool
+	page       int
+	pageSize   int
+	preloads   []string
 }
 
+// Query renders the builder, it doesn't change the builder so it can be rendered again.
 func (q *QueryBuilder) Query() (string, []interface{}) {
-	query := ""
-	if q.query != "" {
-		query = q.query
-	}
+	query := q.query
+	args := append([]interface{}{}, q.args...)
+	config := &DefaultConfigQuery{Dialect: q.dialect}
 	if q.Projection != nil {
-		projectQuery, args := q.Projection.query()
-		if args != nil {
-			q.args = append(q.args, args)
+		projectQuery, projectArgs := q.Projection.query(config)
+		args = append(args, projectArgs...)
+		query = " SELECT " + projectQuery + q.query
+	}
+	for _, clause := range []Querier{q.Predicate, q.groupBy, q.having, q.orderBy, q.limit} {
+		if clause == nil {
+			continue
 		}
+		clauseQuery, clauseArgs := clause.query(config)
+		if clauseQuery != "" {
+			query += " " + clauseQuery
+		}
+		args = append(args, clauseArgs...)
+	}
+	return query, args
+}
 
-		query = " SELECT " + projectQuery
-		query += q.query
-		q.query = query
+// clone copies the builder, the clauses of the copy can be added to without changing q.
+func (q *QueryBuilder) clone() *QueryBuilder {
+	c := *q
+	if w, ok := q.Predicate.(*Where); ok {
+		c.Predicate = &Where{exprs: append([]Expr{}, w.exprs...), keyword: w.keyword}
 	}
-	if q.Predicate != nil {
-		q.query += " "
-		predicateQuery, args := q.Predicate.query()
-		q.query += predicateQuery
-		if args != nil {
-			q.args = append(q.args, args...)
-		}
+	if o, ok := q.orderBy.(*orderBy); ok {
+		c.orderBy = &orderBy{terms: append([]*orderTerm{}, o.terms...)}
 	}
-	if q.orderBy != nil {
-		q.query += " "
-		orderByQuery, _ := q.orderBy.query()
-		q.query += orderByQuery
+	if l, ok := q.limit.(*limitOffset); ok {
+		copied := *l
+		c.limit = &copied
 	}
-	return q.query, q.args
+	if s, ok := q.Projection.(*Selector); ok {
+		c.Projection = &Selector{cols: append([]*C{}, s.cols...)}
+	}
+	if g, ok := q.groupBy.(*groupBy); ok {
+		c.groupBy = &groupBy{cols: append([]*C{}, g.cols...)}
+	}
+	if h, ok := q.having.(*Where); ok {
+		c.having = &Where{exprs: append([]Expr{}, h.exprs...), keyword: h.keyword}
+	}
+	c.args = append([]interface{}{}, q.args...)
+	c.preloads = append([]string{}, q.preloads...)
+	return &c
 }
 
 func (q *QueryBuilder) Select(col *C) *QueryBuilder {
@@ -178,6 +235,15 @@ func (p PredicateOp) toString() string {
 	if p == Like {
 		return "LIKE"
 	}
+	if p == NotEqual {
+		return "<>"
+	}
+	if p == NotLike {
+		return "NOT LIKE"
+	}
+	if p == ILike {
+		return "ILIKE"
+	}
 	return "="
 }
 const (
@@ -187,11 +253,24 @@ const (
 	Greater
 	Equal
 	Like
+	NotEqual
+	NotLike
+	ILike
+	In
+	NotIn
+	IsNull
+	IsNotNull
+	Between
 )
+
+// Predicate compares a column with a value, or with another column when the value is a *C.
+// In and NotIn take a slice, Between a slice of two bounds, IsNull and IsNotNull ignore the value.
 type Predicate struct {
 	col string
 	table string
-	op string
+	fn string
+	raw string
+	op PredicateOp
 	val interface{}
 }
 
@@ -199,45 +278,57 @@ func P(col string, table string, op PredicateOp, val interface{}) *Predicate {
 	return &Predicate{
 		col:   col,
 		table: table,
-		op:    op.toString(),
+		op:    op,
 		val:   val,
 	}
 }
 
+// PCol is P on a column built with Col, on an aggregate like Count for Having, or on a Raw expression.
+func PCol(c *C, op PredicateOp, val interface{}) *Predicate {
+	return &Predicate{
+		col:   c.name,
+		table: c.table,
+		fn:    c.fn,
+		raw:   c.raw,
+		op:    op,
+		val:   val,
+	}
+}
+
+// Where is the WHERE clause of a QueryBuilder, its conditions are joined with AND.
 type Where struct {
-	predicates []*Predicate
+	exprs []Expr
+	keyword string
 }
 
 func (w *Where) Append(querier Querier) Querier {
 	if _, check := querier.(*Where); check {
-		w.predicates = append(w.predicates, querier.(*Where).predicates...)
+		w.exprs = append(w.exprs, querier.(*Where).exprs...)
 	}
 	return w
 }
 
 func (w *Where) query(config ...*DefaultConfigQuery) (string, []interface{}) {
-	query := "WHERE "
-	arguments := []interface{}{}
-	for i, p := range w.predicates {
-		if len(config) > 0 {
-			p.table = config[0].RenameTableAs
-		}
-		query += fmt.Sprintf("`%v`.`%v` %v ?", p.table, p.col, p.op)
-		if i < len(w.predicates) - 1 {
-			query += " AND "
-		}
-		arguments = append(arguments, p.val)
+	d := dialectOf(config...)
+	rename := ""
+	if len(config) > 0 && config[0] != nil {
+		rename = config[0].RenameTableAs
 	}
-	return query, arguments
+	query, arguments := And(w.exprs...).expr(d, rename)
+	if w.keyword != "" {
+		return w.keyword + " " + query, arguments
+	}
+	return "WHERE " + query, arguments
 }
 
-func (q *QueryBuilder) Where(predicate *Predicate) *QueryBuilder {
+// Where adds a condition, the conditions of several calls must all match.
+func (q *QueryBuilder) Where(expr Expr) *QueryBuilder {
 	if q.Predicate == nil {
 		q.Predicate = &Where{
-			predicates: make([]*Predicate, 0),
+			exprs: make([]Expr, 0),
 		}
 	}
-	q.Predicate.(*Where).predicates = append(q.Predicate.(*Where).predicates, predicate)
+	q.Predicate.(*Where).exprs = append(q.Predicate.(*Where).exprs, expr)
 	return q
 }
 
@@ -245,293 +336,217 @@ func (q *QueryBuilder) Where(predicate *Predicate) *QueryBuilder {
 
 func (r *Repo) GetById(need interface{}, preloads ...func() (to interface{}, fk string, pk string, inverse bool)) *QueryBuilder {
 	nv := reflect.Indirect(reflect.ValueOf(need))
-	nvName := nv.Type().Name()
-	nvTable := strings.ToLower(nvName) + "s"
+	nvTable := changeset.TableOf(nv.Type())
 	if len(preloads) == 0 {
 		return &QueryBuilder{
-			query: fmt.Sprintf("FROM `%v`", nvTable),
+			query: fmt.Sprintf("FROM %v", r.dialect.Quote(nvTable)),
 			table: nvTable,
 			args: []interface{}{},
+			dialect: r.dialect,
+			schema: nv.Type(),
 		}
 	}
 	to, fk, pk, inverse := preloads[0]()
 	pv := reflect.Indirect(reflect.ValueOf(to))
-	fmt.Println(pv)
-	pvName := pv.Type().Name()
 
 	nvKey := pk
-	pvTable := strings.ToLower(pvName) + "s"
+	pvTable := changeset.TableOf(pv.Type())
 	pvKey := fk
 	if inverse {
 		nvKey, pvKey = pvKey, nvKey
 	}
-	query := fmt.Sprintf("FROM `%v` INNER JOIN `%v` ON `%v`.`%v` = `%v`.`%v`", nvTable, pvTable, nvTable, nvKey, pvTable, pvKey)
-	fmt.Println(query)
-	return &QueryBuilder{table: nvTable, query: query, args: []interface{}{}}
+	query := fmt.Sprintf("FROM %v INNER JOIN %v ON %v = %v", r.dialect.Quote(nvTable), r.dialect.Quote(pvTable), quoteCol(r.dialect, nvTable, nvKey), quoteCol(r.dialect, pvTable, pvKey))
+	return &QueryBuilder{table: nvTable, query: query, args: []interface{}{}, dialect: r.dialect, schema: nv.Type(), joined: true}
 }
 
+// Condition is kept for compatibility, OrderBy has no effect since the results always follow the order of the rows.
 type Condition struct {
 	OrderBy bool
 }
-// update repo
-func (r *Repo) ParseToStruct(rows*sql.Rows, cast interface{}, cond ...*Condition) ([]interface{}, []interface{}) {
+
+// ParseToStruct scans rows into new values of the type of cast, the rows are closed when it returns.
+// The rows of one id are merged into the first one, so the results follow the ORDER BY of the query.
+// Scan and json errors stop the parsing and name the column they come from.
+func (r *Repo) ParseToStruct(rows *sql.Rows, cast interface{}, cond ...*Condition) ([]interface{}, error) {
+	defer rows.Close()
 	cols, err := rows.Columns()
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 	var scaned = map[interface{}]reflect.Value{}
-	var orderId = []interface{}{}
-	type RelRelation struct {
-		relScaned *reflect.Value
-		fieldRef  string
-		isO2O     bool
-	}
-	fmt.Println(cols, "cast")
 	castReflect := reflect.Indirect(reflect.ValueOf(cast))
+	plan := newScanPlan(castReflect.Type(), cols)
+	rels := make([]reflect.Value, len(plan.rels))
 	results := []interface{}{}
 	for rows.Next() {
-		jsonByteAddrs := map[string][]interface{}{}
-		castedNew := reflect.Indirect(reflect.New(castReflect.Type()))
-		addrs := make([]interface{}, len(cols))
-		rels := map[string]*RelRelation{}
-		for i, col := range cols {
-			_, ok := castedNew.Type().FieldByName(col)
-			isJson := false
-			if !ok {
-				str := strings.Split(col, "$")
-				scName := strings.Split(str[0], "Rel")
-				if _, jsonOk := changeset.JsonFieldsOfSchemas[scName[0]]; jsonOk {
-					if _, jsonOk := changeset.JsonFieldsOfSchemas[scName[0]][str[1]]; jsonOk {
-						isJson = true
-					}
-				}
-				fmt.Println("have json", isJson, str[0], str[1], changeset.JsonFieldsOfSchemas)
-				if len(str) != 2 {
-					var addr interface{}
-					addrs[i] = &addr
-					continue
-				}
-				if _, ok := rels[str[0]]; !ok {
-					rels[str[0]] = &RelRelation{
-						isO2O: false,
-					}
-				}
-				rel := rels[str[0]]
-				fRel := castedNew.FieldByName(str[0])
-				var fRelType reflect.Type
-				if fRel.Type().Kind() == reflect.Slice {
-					fRelType = fRel.Type().Elem()
-				}
-				if fRel.Type().Kind() == reflect.Ptr {
-					fRelType = fRel.Type()
-					rel.isO2O = true
-				}
-				// fRelType is pointer => use elem before use new
-				rel.fieldRef = str[0]
-				if rel.relScaned == nil {
-					var newRel = reflect.New(fRelType.Elem())
-					rel.relScaned = &newRel
-				}
-				_, ok = (*rel.relScaned).Type().Elem().FieldByName(str[1])
-				if isJson {
-					var addr []byte
-					if _, ok := jsonByteAddrs[str[1]]; !ok {
-						jsonByteAddrs[str[1]] = make([]interface{}, 2)
-					}
-					fmt.Println("have json field is", str[1])
-					jsonByteAddrs[str[1]][0] = &addr
-					jsonByteAddrs[str[1]][1] = rel.relScaned
-					fmt.Println(jsonByteAddrs, "byte 2")
-					addrs[i] = &addr
-					continue
-				}
-				if !ok {
-					// check field of rel name exist if not assign empty addr
-					var addr interface{}
-					addrs[i] = &addr
-					continue
-				}
-				addrs[i] = (*rel.relScaned).Elem().FieldByName(str[1]).Addr().Interface()
-				continue
-			}
-			scName := castReflect.Type().Name()
-			if _, jsonOk := changeset.JsonFieldsOfSchemas[scName]; jsonOk {
-				if _, jsonOk := changeset.JsonFieldsOfSchemas[scName][col]; jsonOk {
-					isJson = true
-				}
-			}
-			if isJson {
-				var addr []byte
-				if _, ok := jsonByteAddrs[col]; !ok {
-					jsonByteAddrs[col] = make([]interface{}, 2)
-				}
-				fmt.Println("have json field is", col)
-				jsonByteAddrs[col][0] = &addr
-				jsonByteAddrs[col][1] = &castedNew
-				fmt.Println(jsonByteAddrs, "byte 2")
-				addrs[i] = &addr
-				continue
-			}
-
-			f := castedNew.FieldByName(col)
-			addrs[i] = f.Addr().Interface()
+		castedNew := reflect.New(plan.typ).Elem()
+		addrs := plan.addrs(castedNew, rels)
+		if err := rows.Scan(addrs...); err != nil {
+			return nil, err
 		}
-		rows.Scan(addrs...)
-		for fieldName, byteAndStructAddrJson := range jsonByteAddrs {
-			fmt.Println(byteAndStructAddrJson, "have")
-			if byteAddr, ok := byteAndStructAddrJson[0].(*[]byte); ok {
-				var reflectTypeJsonClass reflect.Type
-				var havePointerSet bool = false
-				if byteAndStructAddrJson[1].(*reflect.Value).Kind() == reflect.Ptr {
-					reflectTypeJsonClass = byteAndStructAddrJson[1].(*reflect.Value).Elem().FieldByName(fieldName).Type().Elem()
-					havePointerSet = true
-				}
-				if byteAndStructAddrJson[1].(*reflect.Value).Kind() == reflect.Struct {
-					reflectTypeJsonClass = byteAndStructAddrJson[1].(*reflect.Value).FieldByName(fieldName).Type().Elem()
-				}
-				rvClassJson := reflect.New(reflectTypeJsonClass).Interface()
-				if err := json.Unmarshal(*byteAddr, rvClassJson); err == nil {
-					fmt.Println(rvClassJson, "cast json okkkk")
-					if havePointerSet {
-						byteAndStructAddrJson[1].(*reflect.Value).Elem().FieldByName(fieldName).Set(reflect.ValueOf(rvClassJson))
-					} else {
-						byteAndStructAddrJson[1].(*reflect.Value).FieldByName(fieldName).Set(reflect.ValueOf(rvClassJson))
-					}
-				} else {
-					fmt.Println("cast json failed", err)
-				}
-			}
+		if err := plan.decodeJSON(castedNew, rels, addrs); err != nil {
+			return nil, err
 		}
-		if _, ok := castedNew.Type().FieldByName("Id"); ok {
-			idVal := castedNew.FieldByName("Id").Interface()
-			if _, ok := scaned[idVal]; !ok {
-				scaned[idVal] = castedNew
-				if len(cond) == 1 {
-					if cond[0].OrderBy {
-						orderId = append(orderId, idVal) // sort id of order by
-					}
-				}
-				fmt.Println(scaned)
-			}
-			fmt.Println("have rels is", rels)
-			for _, rel := range rels {
-				if !rel.isO2O {
-					newVal := reflect.Append(scaned[idVal].FieldByName(rel.fieldRef), *rel.relScaned)
-					scaned[idVal].FieldByName(rel.fieldRef).Set(newVal)
-				} else {
-					scaned[idVal].FieldByName(rel.fieldRef).Set(*rel.relScaned)
-				}
-			}
-			for _, addr := range addrs {
-				rv := reflect.Indirect(reflect.ValueOf(addr))
-				fmt.Println("have value is rv", rv.Interface())
-			}
+		if plan.id == nil {
+			// without an id the rows can't be merged, every row is a result
+			results = append(results, castedNew.Addr().Interface())
+			continue
 		}
-	}
-	if len(orderId) == 0 {
-		for k, v := range scaned {
-			results = append(results, v.Addr().Interface())
-			delete(scaned, k)
+		idVal := castedNew.FieldByIndex(plan.id).Interface()
+		if _, ok := scaned[idVal]; !ok {
+			scaned[idVal] = castedNew
+			results = append(results, castedNew.Addr().Interface())
 		}
-		return results, nil
+		plan.attach(scaned[idVal], rels)
 	}
-	for _, id := range orderId {
-		fmt.Println("order id: ", id)
-		if _, ok := scaned[id]; ok {
-			results = append(results, scaned[id].Addr().Interface())
-			delete(scaned, id)
-		}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 	return results, nil
 }
 
+// RawQuery runs query and scans the rows into new values of the type of cast, ctx cancels the query.
+func (r *Repo) RawQuery(ctx context.Context, query string, args []interface{}, cast interface{}) ([]interface{}, error) {
+	return r.query(ctx, query, args, cast)
+}
 
-func (r *Repo) RawQuery(query string, args []interface{}, cast interface{})  ([]interface{}, []interface{}){
-	stmt, err := r.db.Prepare(query)
-	if err != nil {
-		fmt.Println(err, "prepare")
-		return nil, nil
+// validInsert checks required fields and every validation error before an insert.
+func validInsert(cs *changeset.ChangeSet) error {
+	if !cs.ValidateRequired().Valid() {
+		return &changeset.ValidationError{ChangeSet: cs}
 	}
-	rows, err := stmt.Query(args...)
-	if err != nil {
-		fmt.Println(err, "rows")
+	return nil
+}
+
+// validUpdate only checks validation errors, an update doesn't need every required field to be cast.
+func validUpdate(cs *changeset.ChangeSet) error {
+	if len(cs.Errors) > 0 {
+		return &changeset.ValidationError{ChangeSet: cs}
 	}
+	return nil
+}
 
-	fmt.Println("can query ok")
-	if strings.Contains(query, "ORDER BY") {
-		return r.ParseToStruct(rows, cast, &Condition{OrderBy: true})
+// Save inserts the cast fields of cs, onConflict turns the insert into an upsert.
+// The generated key is set on the schema, on postgres and sqlite it isn't when the conflicting row is kept.
+// On mysql it is the key of the row inserted, updated or kept.
+// The changesets of the associations of cs are inserted with it in a transaction.
+func (r *Repo) Save(ctx context.Context, cs *changeset.ChangeSet, onConflict ...*OnConflict) error {
+	if len(cs.Assocs) > 0 {
+		return r.saveAssocs(ctx, cs, onConflict...)
 	}
-	return r.ParseToStruct(rows, cast)
+	return r.insert(ctx, cs, onConflict...)
 }
 
-func (r *Repo) Save(ctx context.Context, cs *changeset.ChangeSet) error {
-	query, args := r.insertQuery(cs)
-	stmt, err := r.db.PrepareContext(ctx, query)
-	fmt.Println(query, args)
-	if err != nil {
-		fmt.Println("error from prepare query", err)
-		return  err
+func (r *Repo) insert(ctx context.Context, cs *changeset.ChangeSet, onConflict ...*OnConflict) error {
+	if err := validInsert(cs); err != nil {
+		return err
 	}
-	result, err := stmt.ExecContext(ctx, args...)
+	query, args, err := r.insertQuery(cs, onConflict...)
 	if err != nil {
-		fmt.Println("error from exec query", err)
-		return  err
+		return err
 	}
-	id, err := result.LastInsertId()
+	id, err := r.execInsert(ctx, r.conn, cs, query, args, r.returning(onConflict))
 	if err != nil {
-		return err
+		return  r.constraintError(cs, err)
+	}
+	if key := generatedKey(cs); key != nil && id != 0 {
+		setKey(cs, key, id)
 	}
-	cs.ReflectSchema.FieldByName("Id").Set(reflect.ValueOf(uint32(id)))
 	cs.ActionRepo = changeset.ActionInsert
 	return nil
 }
 
-func (r *Repo) SaveTx(ctx context.Context, cs*changeset.ChangeSet, tx *sql.Tx) error {
-	query, args := r.insertQuery(cs)
-	stmt, err := tx.PrepareContext(ctx, query)
-	fmt.Println(query, args)
-	if err != nil {
-		fmt.Println(err)
-		return  err
+// SaveTx is Save on tx without the associations, a changeset with associations fails with ErrAssocs.
+func (r *Repo) SaveTx(ctx context.Context, cs*changeset.ChangeSet, tx *sql.Tx, onConflict ...*OnConflict) error {
+	if len(cs.Assocs) > 0 {
+		return ErrAssocs
 	}
-	result, err := stmt.ExecContext(ctx, args...)
-	if err != nil {
-		fmt.Println(err)
-		return  err
+	if err := validInsert(cs); err != nil {
+		return err
 	}
-	id, err := result.LastInsertId()
+	query, args, err := r.insertQuery(cs, onConflict...)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
-
-	if (cs.Boxes["Id"].GetOps() & (1<<changeset.AI)) != 0 {
-		cs.ReflectSchema.FieldByName("Id").Set(reflect.ValueOf(uint32(id)))
+	id, err := r.execInsert(ctx, tx, cs, query, args, r.returning(onConflict))
+	if err != nil {
+		return  r.constraintError(cs, err)
+	}
+	if key := generatedKey(cs); key != nil && id != 0 {
+		setKey(cs, key, id)
 	}
 	cs.ActionRepo = changeset.ActionInsert
 	return nil
 }
 
 
-func (r *Repo) OpenTx(ctx context.Context) *sql.Tx {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+// OpenTx begins a transaction on the pool, it is RepeatableRead unless opts is given.
+func (r *Repo) OpenTx(ctx context.Context, opts ...*sql.TxOptions) (*sql.Tx, error) {
+	txOpts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead}
+	if len(opts) > 0 && opts[0] != nil {
+		txOpts = opts[0]
+	}
+	return r.db.BeginTx(ctx, txOpts)
+}
+
+// execInsert runs an INSERT and reads back the generated id, by its RETURNING clause when returning is set.
+func (r *Repo) execInsert(ctx context.Context, conn executor, cs *changeset.ChangeSet, query string, args []interface{}, returning bool) (id int64, err error) {
+	event := &QueryEvent{Query: query, Args: args}
+	defer func(start time.Time) {
+		if err == nil {
+			event.RowsAffected = 1
+		}
+		event.Err = err
+		r.logQuery(ctx, start, cs, event)
+	}(time.Now())
+	stmt, err := conn.PrepareContext(ctx, query)
 	if err != nil {
-		return nil
+		return 0, err
 	}
-	return tx
+	defer stmt.Close()
+	key := generatedKey(cs)
+	if key != nil && returning {
+		err = stmt.QueryRowContext(ctx, args...).Scan(&id)
+		if err == sql.ErrNoRows {
+			// ON CONFLICT DO NOTHING kept the existing row
+			return 0, nil
+		}
+		return id, err
+	}
+	result, err := stmt.ExecContext(ctx, args...)
+	if err != nil || key == nil {
+		return 0, err
+	}
+	return result.LastInsertId()
 }
 
-func (r *Repo) insertQuery(cs *changeset.ChangeSet) (string, []interface{}){
-	tb := strings.ToLower(cs.ReflectSchema.Type().Name()) + "s"
-	query := fmt.Sprintf("INSERT INTO `%v` (", tb)
+// exec prepares and runs a statement changing the rows of schema and returns the number of rows affected.
+func (r *Repo) exec(ctx context.Context, conn executor, schema interface{}, query string, args []interface{}) (affected int64, err error) {
+	event := &QueryEvent{Query: query, Args: args}
+	defer func(start time.Time) {
+		event.RowsAffected, event.Err = affected, err
+		r.logQuery(ctx, start, schema, event)
+	}(time.Now())
+	stmt, err := conn.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *Repo) insertQuery(cs *changeset.ChangeSet, onConflict ...*OnConflict) (string, []interface{}, error){
+	tb := cs.TableName()
+	d := r.dialect
+	query := fmt.Sprintf("INSERT INTO %v (", d.Quote(tb))
 	values := " VALUES ("
 	args := []interface{}{}
 	for i, col := range cs.CastedBoxes {
-		if cs.Boxes[col].UpdatedCol != "" {
-			query += fmt.Sprintf("`%v`", cs.Boxes[col].RelTbName+cs.Boxes[col].UpdatedCol)
-		} else {
-			query += fmt.Sprintf("`%v`", col)
-		}
+		query += d.Quote(cs.Column(col))
 		values += "?"
 		if i < len(cs.CastedBoxes) - 1 {
 			query += ", "
@@ -542,62 +557,110 @@ func (r *Repo) insertQuery(cs *changeset.ChangeSet) (string, []interface{}){
 	query += ")"
 	values += ")"
 	query += values
-	return query, args
+	if len(onConflict) > 0 && onConflict[0] != nil {
+		clause, clauseArgs, err := r.conflictClause(onConflict[0], cs, cs.CastedBoxes)
+		if err != nil {
+			return "", nil, err
+		}
+		query += " " + clause
+		args = append(args, clauseArgs...)
+	}
+	if key := generatedKey(cs); key != nil && r.returning(onConflict) {
+		query += " RETURNING " + d.Quote(key.Column)
+	}
+	return d.Rebind(query), args, nil
 }
 
-func (r *Repo) UpdateById(ctx context.Context, cs *changeset.ChangeSet) error {
-	query, args := UpdateQuery(cs)
-	stmt, err := r.db.PrepareContext(ctx, query)
-	if err != nil {
-		fmt.Println(err)
-		return  err
+// returning tells if an INSERT reads its generated key with RETURNING: always on postgres, and for the
+// upserts of sqlite since a row kept or updated on conflict doesn't change last_insert_rowid().
+func (r *Repo) returning(onConflict []*OnConflict) bool {
+	upsert := len(onConflict) > 0 && onConflict[0] != nil
+	return r.dialect.InsertId() == Returning || upsert && r.dialect.Name() == "sqlite"
+}
+
+// generatedKey returns the primary key of cs when the database generates it: an autoincrement key,
+// or an integer Id without pk tag. It's nil when the key is given by the schema.
+func generatedKey(cs *changeset.ChangeSet) *changeset.FieldMeta {
+	pk := changeset.MetaOf(cs.ReflectSchema.Type()).PK
+	if pk == nil {
+		return nil
+	}
+	if (pk.Ops & (1 << changeset.AI)) != 0 {
+		return pk
+	}
+	switch pk.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if (pk.Ops & (1 << changeset.PK)) == 0 {
+			return pk
+		}
 	}
+	return nil
+}
 
-	_, err = stmt.ExecContext(ctx, args...)
+// setKey sets the generated key of cs to id, converted to the type of the field.
+func setKey(cs *changeset.ChangeSet, key *changeset.FieldMeta, id int64) {
+	field := cs.ReflectSchema.FieldByIndex(key.Index)
+	if field.CanSet() && reflect.TypeOf(id).ConvertibleTo(field.Type()) {
+		field.Set(reflect.ValueOf(id).Convert(field.Type()))
+	}
+}
+
+func (r *Repo) UpdateById(ctx context.Context, cs *changeset.ChangeSet) error {
+	if err := validUpdate(cs); err != nil {
+		return err
+	}
+	query, args, err := UpdateQuery(cs, r.dialect)
 	if err != nil {
-		fmt.Println(err)
-		return  err
+		return err
+	}
+	if _, err := r.exec(ctx, r.conn, cs, query, args); err != nil {
+		return  r.constraintError(cs, err)
 	}
 	cs.ActionRepo = changeset.ActionUpdate
 	return nil
 }
 
 func (r *Repo) UpdateTxById(ctx context.Context, cs *changeset.ChangeSet, tx *sql.Tx) error {
-	query, args := UpdateQuery(cs)
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		fmt.Println(err)
-		return  err
+	if err := validUpdate(cs); err != nil {
+		return err
 	}
-
-	_, err = stmt.ExecContext(ctx, args...)
+	query, args, err := UpdateQuery(cs, r.dialect)
 	if err != nil {
-		fmt.Println(err)
-		return  err
+		return err
+	}
+	if _, err := r.exec(ctx, tx, cs, query, args); err != nil {
+		return  r.constraintError(cs, err)
 	}
 	cs.ActionRepo = changeset.ActionUpdate
 	return nil
 }
 
 
-func UpdateQuery(cs *changeset.ChangeSet) (string, []interface{}) {
-	tbName := strings.ToLower(cs.ReflectSchema.Type().Name()) + "s"
-	query := fmt.Sprintf("UPDATE %v SET ", tbName)
+// UpdateQuery renders the UPDATE of the cast fields of cs matching its row by the primary key,
+// a schema without primary key fails with ErrNoPrimaryKey.
+func UpdateQuery(cs *changeset.ChangeSet, dialect ...Dialect) (string, []interface{}, error) {
+	d := MySQL
+	if len(dialect) > 0 && dialect[0] != nil {
+		d = dialect[0]
+	}
+	tbName := cs.TableName()
+	query := fmt.Sprintf("UPDATE %v SET ", d.Quote(tbName))
 	args := []interface{}{}
 	for i, col := range cs.CastedBoxes {
-		if cs.Boxes[col].UpdatedCol != "" {
-			query += fmt.Sprintf("`%v` = ?", cs.Boxes[col].RelTbName + cs.Boxes[col].UpdatedCol)
-		} else {
-			query += fmt.Sprintf("`%v` = ?", col)
-		}
+		query += fmt.Sprintf("%v = ?", d.Quote(cs.Column(col)))
 		args = append(args, cs.Boxes[col].GetVal())
 		if i < len(cs.CastedBoxes) - 1 {
 			query += ", "
 		}
 	}
-	query += " WHERE `Id` = ?"
-	args = append(args, cs.ReflectSchema.FieldByName("Id").Interface())
-	return query, args
+	pk := changeset.MetaOf(cs.ReflectSchema.Type()).PK
+	if pk == nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrNoPrimaryKey, cs.ReflectSchema.Type().Name())
+	}
+	query += fmt.Sprintf(" WHERE %v = ?", d.Quote(pk.Column))
+	args = append(args, cs.ReflectSchema.FieldByIndex(pk.Index).Interface())
+	return d.Rebind(query), args, nil
 }
 
 type Rel struct {
@@ -620,6 +683,7 @@ type QueryRel struct {
 	args []interface{}
 	index int
 	joinedKeysCache []*CacheKey
+	dialect Dialect
 }
 
 func (q *QueryRel) OpenRel(rel *Rel) *QueryRel {
@@ -627,6 +691,11 @@ func (q *QueryRel) OpenRel(rel *Rel) *QueryRel {
 	return q
 }
 
+func (q *QueryRel) WithDialect(d Dialect) *QueryRel {
+	q.dialect = d
+	return q
+}
+
 func (q *QueryRel) ParseToQuery() (string, []interface{}) {
 	dfs(q, true)
 	return q.query, q.args
@@ -656,6 +725,7 @@ func dfs(q *QueryRel, rebuild bool) {
 		if q.index >= len(q.rels) {
 			return
 		}
+		d := dialectOf(&DefaultConfigQuery{Dialect: q.dialect})
 		index := q.index
 		source := q.rels[index]
 		var haveExpandQuery bool
@@ -669,10 +739,10 @@ func dfs(q *QueryRel, rebuild bool) {
 			}
 			for i := index; i < len(q.rels)-1; i++ {
 				if q.rels[i+1].builder != nil {
-					fmt.Println(IncludeColAs, index, i)
 					projectQuery, _ := q.rels[i+1].builder.Projection.query(&DefaultConfigQuery{
 						IncludeColAs: IncludeColAs,
 						RenameTableAs: fmt.Sprintf("%v_%v", "r", index+1),
+						Dialect: d,
 					})
 					if projectQuery != "" {
 						if !haveExpandQuery {
@@ -689,7 +759,7 @@ func dfs(q *QueryRel, rebuild bool) {
 		// load self builder
 		if q.rels[index].builder != nil {
 			if q.rels[index].builder.Projection != nil {
-				selfProjectQuery, _ := q.rels[index].builder.Projection.query()
+				selfProjectQuery, _ := q.rels[index].builder.Projection.query(&DefaultConfigQuery{Dialect: d})
 				if selfProjectQuery != "" {
 					if !haveExpandQuery {
 						haveExpandQuery = true
@@ -704,9 +774,9 @@ func dfs(q *QueryRel, rebuild bool) {
 		}
 		if index > 0 {
 			if haveExpandQuery {
-				q.query += fmt.Sprintf(", `%v`.`%v`", q.rels[index-1].to, q.rels[index-1].toKey)
+				q.query += ", " + quoteCol(d, q.rels[index-1].to, q.rels[index-1].toKey)
 			} else {
-				q.query += fmt.Sprintf("SELECT `%v`.`%v`", q.rels[index-1].to, q.rels[index-1].toKey)
+				q.query += "SELECT " + quoteCol(d, q.rels[index-1].to, q.rels[index-1].toKey)
 			}
 			q.query += " "
 		}
@@ -715,15 +785,15 @@ func dfs(q *QueryRel, rebuild bool) {
 		dfs(q, false)
 		// backtracking
 		if index == len(q.rels) - 1 {
-			q.query += fmt.Sprintf("%v ON `%v`.`%v` = `%v`.`%v`", source.to, source.from, source.fromKey, source.to, source.toKey)
+			q.query += fmt.Sprintf("%v ON %v = %v", source.to, quoteCol(d, source.from, source.fromKey), quoteCol(d, source.to, source.toKey))
 		}
 		if index > 0 {
 			tbNameAs := fmt.Sprintf("%v_%v", "r", index)
-			q.query += fmt.Sprintf(") AS `%v` ON `%v`.`%v` = `%v`.`%v`",tbNameAs, q.rels[index-1].from, q.rels[index-1].fromKey, tbNameAs, q.rels[index-1].toKey)
+			q.query += fmt.Sprintf(") AS %v ON %v = %v", d.Quote(tbNameAs), quoteCol(d, q.rels[index-1].from, q.rels[index-1].fromKey), quoteCol(d, tbNameAs, q.rels[index-1].toKey))
 		}
 		if q.rels[index].builder != nil {
 			if q.rels[index].builder.Predicate != nil {
-				selfPredicateQuery, args := q.rels[index].builder.Predicate.query()
+				selfPredicateQuery, args := q.rels[index].builder.Predicate.query(&DefaultConfigQuery{Dialect: d})
 				if selfPredicateQuery != "" {
 					q.query += selfPredicateQuery
 					q.args = append(q.args, args...)
@@ -742,7 +812,6 @@ func JoinMultipleBuilder(left, right *QueryBuilder) {
 			append_name := lv.Type().Field(i).Name
 			if _, inLeft := lv.Type().FieldByName(append_name); inLeft {
 				if _, inRight := rv.Type().FieldByName(append_name); inRight {
-					fmt.Println(lv.FieldByName(append_name), append_name, "exist")
 					var from Querier
 					var to Querier
 					leftAppendVal := lv.FieldByName(append_name).Interface()
@@ -772,7 +841,6 @@ func JoinMultipleBuilder(left, right *QueryBuilder) {
 }
 
 func swapFromAndTo(rel *Rel, indexRel int, n int) {
-	fmt.Println(rel, indexRel, n, "not swap")
 	rel.fromKey, rel.toKey = rel.toKey, rel.fromKey
 	if indexRel == n - 1 {
 		rel.from, rel.to = rel.to, rel.from
@@ -780,7 +848,6 @@ func swapFromAndTo(rel *Rel, indexRel int, n int) {
 		rel.from = rel.to
 		rel.to = fmt.Sprintf("r_%v", indexRel+1)
 	}
-	fmt.Println(rel, indexRel, n, "swaped")
 }
 
 func JoinProjectBuilder(query *string, projectQuery *string) {
@@ -791,7 +858,6 @@ func ReplaceStringHaveAs(query *string) {
 	var startPoint int = 0
 	var index = 0
 	for index < len(*query) {
-		fmt.Println(*query, string((*query)[index]), index)
 		if string((*query)[index]) == "A" && string((*query)[index-1]) == " " && index > 0 {
 			if index < len(*query) - 3 {
 				x, y := (*query)[index+1], (*query)[index+2]
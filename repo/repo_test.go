@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+	"github.com/go-sql-driver/mysql"
+)
+
+type Account struct {
+	Id    uint32
+	Name  string
+	Email string
+}
+
+func (a *Account) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":    changeset.NewBox().Ops(changeset.AI),
+		"Name":  changeset.NewBox().Ops(changeset.NotNullable).Size(32),
+		"Email": changeset.NewBox().Ops(changeset.NotNullable).Size(64),
+	}
+}
+
+type AccountMsg struct {
+	AccountName  string
+	AccountEmail string
+}
+
+func TestNewRepoIndependent(t *testing.T) {
+	primary, err := NewRepo(&mysql.Config{Net: "tcp", Addr: "127.0.0.1:3306", DBName: "primary"}, MaxOpenConns(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	analytics, err := NewRepo(&mysql.Config{Net: "tcp", Addr: "127.0.0.1:3306", DBName: "analytics"}, MaxIdleConns(1), ConnMaxLifetime(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer analytics.Close()
+	if primary == analytics || primary.DB() == analytics.DB() {
+		t.Fatalf("NewRepo must return independent repos")
+	}
+	if primary.DB().Stats().MaxOpenConnections != 4 {
+		t.Fatalf("MaxOpenConns option not applied")
+	}
+}
+
+func TestDeclaredPrimaryKey(t *testing.T) {
+	r, db := newFakeRepo(Postgres, func(query string, args []interface{}) *fakeResult {
+		if strings.HasPrefix(query, "SELECT 1") {
+			return rowsOf("1")
+		}
+		if strings.HasPrefix(query, " SELECT") {
+			return rowsOf("Code,Name", []driver.Value{"fr", "France"}, []driver.Value{"fr", "France"})
+		}
+		return nil
+	})
+	defer r.Close()
+	ctx := context.Background()
+	country := &Country{Code: "fr"}
+	cs := changeset.CastValues(country, map[string]interface{}{"Code": "fr", "Name": "France"})
+	if err := r.Save(ctx, cs); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UpdateById(ctx, changeset.CastValues(country, map[string]interface{}{"Name": "République"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ValidateUnique(ctx, changeset.CastValues(country, map[string]interface{}{"Name": "France"}), "Name"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`INSERT INTO "countries" ("Code", "Name") VALUES ($1, $2)`,
+		`UPDATE "countries" SET "Name" = $1 WHERE "Code" = $2`,
+		`SELECT 1 FROM "countries" WHERE "Name" = $1 AND "Code" <> $2 LIMIT 1`,
+	}
+	if got := db.Queries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	countries, err := All[Country](ctx, r, nil)
+	if err != nil || len(countries) != 1 {
+		t.Fatalf("rows must be merged by the primary key, got %+v %v", countries, err)
+	}
+}
+
+func TestUpdateWithoutPrimaryKey(t *testing.T) {
+	r, db := newFakeRepo(MySQL, nil)
+	defer r.Close()
+	cs := changeset.CastValues(&PostStats{}, map[string]interface{}{"Posts": 3})
+	if _, _, err := UpdateQuery(cs); !errors.Is(err, ErrNoPrimaryKey) {
+		t.Fatalf("expected ErrNoPrimaryKey, got %v", err)
+	}
+	if err := r.UpdateById(context.Background(), cs); !errors.Is(err, ErrNoPrimaryKey) {
+		t.Fatalf("expected ErrNoPrimaryKey, got %v", err)
+	}
+	if got := db.Queries(); len(got) != 0 {
+		t.Fatalf("no statement must run, got %q", got)
+	}
+}
@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+// scanCol tells where one result column goes, rel is -1 for the scanned schema itself.
+type scanCol struct {
+	name    string
+	rel     int
+	index   []int
+	json    bool
+	discard bool
+}
+
+// scanPlan maps the result columns to field index paths once per result set,
+// a column is a field (or its declared column name) or <Relation>$<field> for a preloaded relation.
+// id is the primary key when it is selected, the rows having the same one are merged.
+type scanPlan struct {
+	typ  reflect.Type
+	id   []int
+	cols []*scanCol
+	rels []*changeset.RelationMeta
+}
+
+func newScanPlan(typ reflect.Type, cols []string) *scanPlan {
+	meta := changeset.MetaOf(typ)
+	plan := &scanPlan{
+		typ:  typ,
+		cols: make([]*scanCol, len(cols)),
+	}
+	relIndex := map[string]int{}
+	for i, col := range cols {
+		f, ok := meta.FieldByColumn(col)
+		if !ok {
+			f, ok = meta.Field(col)
+		}
+		if ok {
+			plan.cols[i] = &scanCol{rel: -1, index: f.Index, json: f.JSON}
+			if f == meta.PK {
+				plan.id = f.Index
+			}
+			continue
+		}
+		if sf, ok := typ.FieldByName(col); ok {
+			plan.cols[i] = &scanCol{rel: -1, index: sf.Index}
+			continue
+		}
+		str := strings.Split(col, "$")
+		rel, ok := meta.Relations[str[0]]
+		if len(str) != 2 || !ok {
+			plan.cols[i] = &scanCol{discard: true}
+			continue
+		}
+		relMeta := changeset.MetaOf(rel.Elem)
+		c := &scanCol{}
+		if f, ok := relMeta.FieldByColumn(str[1]); ok {
+			c.index, c.json = f.Index, f.JSON
+		} else if sf, ok := rel.Elem.FieldByName(str[1]); ok {
+			c.index = sf.Index
+		} else {
+			// the relation has no such field, the column is scanned then dropped
+			plan.cols[i] = &scanCol{discard: true}
+			continue
+		}
+		if _, ok := relIndex[rel.Name]; !ok {
+			relIndex[rel.Name] = len(plan.rels)
+			plan.rels = append(plan.rels, rel)
+		}
+		c.rel = relIndex[rel.Name]
+		plan.cols[i] = c
+	}
+	for i, col := range cols {
+		plan.cols[i].name = col
+	}
+	return plan
+}
+
+// addrs returns the scan destinations of a new row and of the new relation rows.
+func (p *scanPlan) addrs(row reflect.Value, rels []reflect.Value) []interface{} {
+	for i, rel := range p.rels {
+		rels[i] = reflect.New(rel.Elem)
+	}
+	addrs := make([]interface{}, len(p.cols))
+	for i, c := range p.cols {
+		switch {
+		case c.discard:
+			var addr interface{}
+			addrs[i] = &addr
+		case c.json:
+			var addr []byte
+			addrs[i] = &addr
+		case c.rel < 0:
+			addrs[i] = row.FieldByIndex(c.index).Addr().Interface()
+		default:
+			addrs[i] = rels[c.rel].Elem().FieldByIndex(c.index).Addr().Interface()
+		}
+	}
+	return addrs
+}
+
+// decodeJSON unmarshals the bytes scanned for the JSON columns into their fields, errors name the column.
+func (p *scanPlan) decodeJSON(row reflect.Value, rels []reflect.Value, addrs []interface{}) error {
+	for i, c := range p.cols {
+		if !c.json {
+			continue
+		}
+		data := *addrs[i].(*[]byte)
+		if data == nil {
+			continue
+		}
+		target := row
+		if c.rel >= 0 {
+			target = rels[c.rel].Elem()
+		}
+		field := target.FieldByIndex(c.index)
+		decoded := reflect.New(field.Type())
+		if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+			return fmt.Errorf("repo: decode json column %q: %w", c.name, err)
+		}
+		field.Set(decoded.Elem())
+	}
+	return nil
+}
+
+// attach adds the relation rows scanned with a row to the first row having the same id.
+func (p *scanPlan) attach(row reflect.Value, rels []reflect.Value) {
+	for i, rel := range p.rels {
+		field := row.FieldByIndex(rel.Index)
+		value := rels[i]
+		if rel.Many {
+			if field.Type().Elem().Kind() != reflect.Ptr {
+				value = value.Elem()
+			}
+			field.Set(reflect.Append(field, value))
+		} else {
+			field.Set(value)
+		}
+	}
+}
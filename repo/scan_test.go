@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+type Author struct {
+	Id    uint32
+	Name  string `ecto:"column:author_name"`
+	Posts []*Post
+}
+
+func TestParseToStructRelations(t *testing.T) {
+	r, _ := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		return rowsOf("Id,author_name,Posts$Id,Posts$Title,Posts$Meta,Unknown",
+			[]driver.Value{int64(1), "ann", int64(10), "first", []byte(`{"tags":["a"]}`), "x"},
+			[]driver.Value{int64(1), "ann", int64(11), "second", nil, "x"},
+			[]driver.Value{int64(2), "bob", int64(12), "third", nil, "x"},
+		)
+	})
+	defer r.Close()
+	rows, err := r.db.QueryContext(context.Background(), "SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	results, err := r.ParseToStruct(rows, &Author{}, &Condition{OrderBy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %v authors", len(results))
+	}
+	ann := results[0].(*Author)
+	if ann.Name != "ann" || len(ann.Posts) != 2 || ann.Posts[1].Title != "second" {
+		t.Fatalf("unexpected author %+v", ann)
+	}
+	if ann.Posts[0].Meta == nil || ann.Posts[0].Meta.Tags[0] != "a" || ann.Posts[1].Meta != nil {
+		t.Fatalf("json column of the relation not decoded")
+	}
+	if meta := changeset.MetaOf(&Author{}); meta.Relations["Posts"] == nil || !meta.Relations["Posts"].Many {
+		t.Fatalf("relation metadata not computed")
+	}
+}
+
+func TestRawQueryErrors(t *testing.T) {
+	res := rowsOf("Id,Title,Meta", []driver.Value{int64(1), "first", []byte(`{"tags":`)})
+	r, _ := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		return res
+	})
+	defer r.Close()
+	ctx := context.Background()
+	_, err := r.RawQuery(ctx, "SELECT * FROM `posts`", nil, &Post{})
+	if err == nil || !strings.Contains(err.Error(), `"Meta"`) {
+		t.Fatalf("expected a json error on Meta, got %v", err)
+	}
+	res = rowsOf("Id,Title", []driver.Value{"abc", "first"})
+	_, err = r.RawQuery(ctx, "SELECT * FROM `posts`", nil, &Post{})
+	if err == nil || !strings.Contains(err.Error(), `"Id"`) {
+		t.Fatalf("expected a scan error on Id, got %v", err)
+	}
+	res = &fakeResult{err: errors.New("table doesn't exist")}
+	if _, err = r.RawQuery(ctx, "SELECT * FROM `posts`", nil, &Post{}); err == nil {
+		t.Fatalf("expected the query error")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = r.RawQuery(canceled, "SELECT * FROM `posts`", nil, &Post{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build go1.21
+
+package repo
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger logs the events at debug level on l, failed statements are logged at error level.
+func SlogLogger(l *slog.Logger) Logger {
+	return LoggerFunc(func(ctx context.Context, event *QueryEvent) {
+		level := slog.LevelDebug
+		attrs := []slog.Attr{
+			slog.String("query", event.Query),
+			slog.Any("args", event.Args),
+			slog.Duration("duration", event.Duration),
+			slog.Int64("rows_affected", event.RowsAffected),
+			slog.String("schema", event.Schema),
+		}
+		if event.Err != nil {
+			level = slog.LevelError
+			attrs = append(attrs, slog.String("error", event.Err.Error()))
+		}
+		l.LogAttrs(ctx, level, "repo query", attrs...)
+	})
+}
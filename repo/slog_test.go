@@ -0,0 +1,68 @@
+//go:build go1.21
+
+package repo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+// recordHandler keeps the records it handles.
+type recordHandler struct {
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+func (h *recordHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *recordHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+func attrsOf(record slog.Record) map[string]slog.Value {
+	attrs := map[string]slog.Value{}
+	record.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value
+		return true
+	})
+	return attrs
+}
+
+func TestSlogLogger(t *testing.T) {
+	h := &recordHandler{}
+	logger := SlogLogger(slog.New(h))
+	ctx := context.Background()
+	logger.LogQuery(ctx, &QueryEvent{Query: "SELECT 1", Args: []interface{}{7}, RowsAffected: 1, Schema: "Account"})
+	logger.LogQuery(ctx, &QueryEvent{Query: "UPDATE accounts", Err: errors.New("connection reset")})
+	if len(h.records) != 2 {
+		t.Fatalf("expected 2 records, got %v", len(h.records))
+	}
+	ok, failed := h.records[0], h.records[1]
+	if ok.Level != slog.LevelDebug || failed.Level != slog.LevelError || ok.Message != "repo query" {
+		t.Fatalf("unexpected levels %v %v or message %q", ok.Level, failed.Level, ok.Message)
+	}
+	attrs := attrsOf(ok)
+	if attrs["query"].String() != "SELECT 1" || !reflect.DeepEqual(attrs["args"].Any(), []interface{}{7}) ||
+		attrs["rows_affected"].Int64() != 1 || attrs["schema"].String() != "Account" {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+	if _, ok := attrs["error"]; ok {
+		t.Fatalf("a successful query has no error attribute")
+	}
+	if attrs = attrsOf(failed); attrs["error"].String() != "connection reset" || attrs["query"].String() != "UPDATE accounts" {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+}
@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrCloseTx = errors.New("repo: can't close the pool of a transaction")
+
+// executor is implemented by *sql.DB and *sql.Tx.
+type executor interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Transaction runs fn with a Repo bound to a new transaction, every method of tx runs inside it.
+// The transaction is committed when fn returns nil and rolled back when fn returns an error or panics.
+// Called on a tx Repo it nests with a SAVEPOINT, opts only apply to the outermost transaction.
+func (r *Repo) Transaction(ctx context.Context, opts *sql.TxOptions, fn func(tx *Repo) error) (err error) {
+	if r.tx != nil {
+		return r.savepoint(ctx, fn)
+	}
+	sqlTx, err := r.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	tx := r.withTx(sqlTx, 0)
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := sqlTx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+			}
+			return
+		}
+		err = sqlTx.Commit()
+	}()
+	return fn(tx)
+}
+
+// Tx returns the transaction of a Repo given by Transaction, nil for a Repo on the pool.
+func (r *Repo) Tx() *sql.Tx {
+	return r.tx
+}
+
+func (r *Repo) withTx(sqlTx *sql.Tx, savepoints int) *Repo {
+	tx := *r
+	tx.conn = sqlTx
+	tx.tx = sqlTx
+	tx.savepoints = savepoints
+	return &tx
+}
+
+// savepoint runs fn between SAVEPOINT and RELEASE, an error or a panic of fn rolls back to the savepoint only.
+func (r *Repo) savepoint(ctx context.Context, fn func(tx *Repo) error) (err error) {
+	name := fmt.Sprintf("sp_%d", r.savepoints+1)
+	if err := r.execTx(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	tx := r.withTx(r.tx, r.savepoints+1)
+	defer func() {
+		if p := recover(); p != nil {
+			r.execTx(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := r.execTx(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+			}
+			return
+		}
+		err = r.execTx(ctx, "RELEASE SAVEPOINT "+name)
+	}()
+	return fn(tx)
+}
+
+// execTx runs a statement controlling the transaction.
+func (r *Repo) execTx(ctx context.Context, query string) error {
+	start := time.Now()
+	_, err := r.tx.ExecContext(ctx, query)
+	r.logQuery(ctx, start, nil, &QueryEvent{Query: query, Err: err})
+	return err
+}
@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+func newAccount(name string) *changeset.ChangeSet {
+	return changeset.CastClass(&Account{}, &AccountMsg{AccountName: name, AccountEmail: name + "@mail.com"})
+}
+
+// statements returns the recorded queries cut to their first word.
+func statements(db *fakeDB) []string {
+	stmts := []string{}
+	for _, query := range db.Queries() {
+		if strings.HasPrefix(query, "INSERT") || strings.HasPrefix(query, "DELETE") {
+			query = strings.Fields(query)[0]
+		}
+		stmts = append(stmts, query)
+	}
+	return stmts
+}
+
+func TestTransaction(t *testing.T) {
+	r, db := newFakeRepo(MySQL, nil)
+	defer r.Close()
+	ctx := context.Background()
+	err := r.Transaction(ctx, &sql.TxOptions{ReadOnly: true}, func(tx *Repo) error {
+		if tx.Tx() == nil {
+			t.Fatalf("tx repo without transaction")
+		}
+		if err := tx.Close(); !errors.Is(err, ErrCloseTx) {
+			t.Fatalf("closing a tx repo must not close the pool, got %v", err)
+		}
+		if err := tx.Save(ctx, newAccount("ann")); err != nil {
+			return err
+		}
+		return tx.Delete(ctx, &Account{Id: 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BEGIN", "INSERT", "DELETE", "COMMIT"}
+	if got := statements(db); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	r, db := newFakeRepo(MySQL, nil)
+	defer r.Close()
+	ctx := context.Background()
+	failed := errors.New("failed")
+	err := r.Transaction(ctx, nil, func(tx *Repo) error {
+		tx.Save(ctx, newAccount("ann"))
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Fatalf("expected the panic to be repanicked, got %v", p)
+			}
+		}()
+		r.Transaction(ctx, nil, func(tx *Repo) error {
+			panic("boom")
+		})
+	}()
+	want := []string{"BEGIN", "INSERT", "ROLLBACK", "BEGIN", "ROLLBACK"}
+	if got := statements(db); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestTransactionBeginError(t *testing.T) {
+	r, _ := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		if query == "BEGIN" {
+			return &fakeResult{err: errors.New("too many connections")}
+		}
+		return nil
+	})
+	defer r.Close()
+	called := false
+	err := r.Transaction(context.Background(), nil, func(tx *Repo) error {
+		called = true
+		return nil
+	})
+	if err == nil || called {
+		t.Fatalf("begin error not returned")
+	}
+	if _, err := r.OpenTx(context.Background()); err == nil {
+		t.Fatalf("OpenTx must return the begin error")
+	}
+}
+
+func TestNestedTransaction(t *testing.T) {
+	r, db := newFakeRepo(MySQL, nil)
+	defer r.Close()
+	ctx := context.Background()
+	err := r.Transaction(ctx, nil, func(tx *Repo) error {
+		tx.Transaction(ctx, nil, func(inner *Repo) error {
+			inner.Save(ctx, newAccount("ann"))
+			return errors.New("skip ann")
+		})
+		return tx.Transaction(ctx, nil, func(inner *Repo) error {
+			return inner.Transaction(ctx, nil, func(deeper *Repo) error {
+				return deeper.Save(ctx, newAccount("bob"))
+			})
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"BEGIN",
+		"SAVEPOINT sp_1", "INSERT", "ROLLBACK TO SAVEPOINT sp_1",
+		"SAVEPOINT sp_1", "SAVEPOINT sp_2", "INSERT", "RELEASE SAVEPOINT sp_2", "RELEASE SAVEPOINT sp_1",
+		"COMMIT",
+	}
+	if got := statements(db); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+// Updates are the changes of UpdateAll, the keys are fields (or columns) of the queried schema.
+// Set assigns the values, Inc adds them and Push appends them to an array (or JSON array) column.
+type Updates struct {
+	Set  map[string]interface{}
+	Inc  map[string]interface{}
+	Push map[string]interface{}
+}
+
+// UpdateAll updates every row matched by the Where predicates of qb without loading them
+// and returns the number of rows affected. qb can't have joins, Limit, OrderBy, GroupBy or Having.
+func (r *Repo) UpdateAll(ctx context.Context, qb *QueryBuilder, updates *Updates) (int64, error) {
+	query, args, err := UpdateAllQuery(qb, updates)
+	if err != nil {
+		return 0, err
+	}
+	return r.exec(ctx, r.conn, qb.schema, query, args)
+}
+
+// UpdateAllQuery renders the UPDATE of UpdateAll in the dialect of qb.
+func UpdateAllQuery(qb *QueryBuilder, updates *Updates) (string, []interface{}, error) {
+	if err := bulkTarget(qb); err != nil {
+		return "", nil, err
+	}
+	d := qb.dialect
+	if d == nil {
+		d = MySQL
+	}
+	sets := []string{}
+	args := []interface{}{}
+	if updates != nil {
+		for _, field := range sortedKeys(updates.Set) {
+			col, value := bulkColumn(qb, field, updates.Set[field])
+			sets = append(sets, fmt.Sprintf("%v = ?", d.Quote(col)))
+			args = append(args, value)
+		}
+		for _, field := range sortedKeys(updates.Inc) {
+			col, _ := bulkColumn(qb, field, nil)
+			sets = append(sets, fmt.Sprintf("%v = %v + ?", d.Quote(col), d.Quote(col)))
+			args = append(args, updates.Inc[field])
+		}
+		for _, field := range sortedKeys(updates.Push) {
+			col, _ := bulkColumn(qb, field, nil)
+			sets = append(sets, fmt.Sprintf("%v = %v", d.Quote(col), d.ArrayAppend(col)))
+			args = append(args, updates.Push[field])
+		}
+	}
+	if len(sets) == 0 {
+		return "", nil, fmt.Errorf("repo: UpdateAll of %v has nothing to update", qb.table)
+	}
+	query := fmt.Sprintf("UPDATE %v SET %v", d.Quote(qb.table), strings.Join(sets, ", "))
+	where, whereArgs := bulkWhere(qb)
+	return d.Rebind(query + where), append(args, whereArgs...), nil
+}
+
+// DeleteAll deletes every row matched by the Where predicates of qb and returns the number of rows deleted.
+// qb can't have joins, Limit, OrderBy, GroupBy or Having.
+func (r *Repo) DeleteAll(ctx context.Context, qb *QueryBuilder) (int64, error) {
+	query, args, err := DeleteAllQuery(qb)
+	if err != nil {
+		return 0, err
+	}
+	return r.exec(ctx, r.conn, qb.schema, query, args)
+}
+
+// DeleteAllQuery renders the DELETE of DeleteAll in the dialect of qb.
+func DeleteAllQuery(qb *QueryBuilder) (string, []interface{}, error) {
+	if err := bulkTarget(qb); err != nil {
+		return "", nil, err
+	}
+	d := qb.dialect
+	if d == nil {
+		d = MySQL
+	}
+	where, args := bulkWhere(qb)
+	return d.Rebind(fmt.Sprintf("DELETE FROM %v", d.Quote(qb.table)) + where), args, nil
+}
+
+func bulkTarget(qb *QueryBuilder) error {
+	if qb == nil || qb.table == "" {
+		return fmt.Errorf("repo: a query on a table is needed")
+	}
+	if qb.joined {
+		return fmt.Errorf("repo: can't update or delete %v through a join", qb.table)
+	}
+	if qb.limit != nil || qb.orderBy != nil || qb.groupBy != nil || qb.having != nil {
+		// only the Where predicates are rendered, the other clauses would be dropped silently
+		return fmt.Errorf("repo: can't update or delete %v with Limit, OrderBy, GroupBy or Having", qb.table)
+	}
+	return nil
+}
+
+func bulkWhere(qb *QueryBuilder) (string, []interface{}) {
+	if qb.Predicate == nil {
+		return "", nil
+	}
+	where, args := qb.Predicate.query(&DefaultConfigQuery{Dialect: qb.dialect})
+	return " " + where, args
+}
+
+// bulkColumn maps a field of the queried schema to its column, JSON values are encoded.
+func bulkColumn(qb *QueryBuilder, field string, value interface{}) (string, interface{}) {
+	if qb.schema == nil {
+		return field, value
+	}
+	f, ok := changeset.MetaOf(qb.schema).Field(field)
+	if !ok {
+		return field, value
+	}
+	if f.JSON && value != nil {
+		if _, raw := value.([]byte); !raw {
+			if data, err := json.Marshal(value); err == nil {
+				value = data
+			}
+		}
+	}
+	return f.Column, value
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpdateAll(t *testing.T) {
+	r, db := newFakeRepo(Postgres, func(query string, args []interface{}) *fakeResult {
+		return &fakeResult{rowsAffected: 3}
+	})
+	defer r.Close()
+	expired := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	qb := From[Post](r).Where(P("AccountId", "posts", Equal, 7)).Where(P("Views", "posts", Less, 10))
+	n, err := r.UpdateAll(context.Background(), qb, &Updates{
+		Set:  map[string]interface{}{"Title": "expired", "Meta": &PostMeta{Tags: []string{"old"}}},
+		Inc:  map[string]interface{}{"Views": 1},
+		Push: map[string]interface{}{"Tags": expired},
+	})
+	if err != nil || n != 3 {
+		t.Fatalf("got %v, %v", n, err)
+	}
+	want := `UPDATE "posts" SET "Meta" = $1, "Title" = $2, "Views" = "Views" + $3, "Tags" = array_append("Tags", $4) WHERE "posts"."AccountId" = $5 AND "posts"."Views" < $6`
+	if got := db.Queries()[0]; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	args := db.args[0]
+	if string(args[0].([]byte)) != `{"tags":["old"]}` || args[2] != int64(1) || args[5] != int64(10) {
+		t.Fatalf("unexpected args %v", args)
+	}
+	if _, err := r.UpdateAll(context.Background(), qb, &Updates{}); err == nil {
+		t.Fatalf("an empty update must be rejected")
+	}
+}
+
+func TestDeleteAll(t *testing.T) {
+	r, db := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		return &fakeResult{rowsAffected: 2}
+	})
+	defer r.Close()
+	n, err := r.DeleteAll(context.Background(), From[Account](r).Where(P("Name", "accounts", Like, "test%")))
+	if err != nil || n != 2 {
+		t.Fatalf("got %v, %v", n, err)
+	}
+	want := []string{"DELETE FROM `accounts` WHERE `accounts`.`Name` LIKE ?"}
+	if got := db.Queries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	joined := r.GetById(&Account{}, func() (interface{}, string, string, bool) {
+		return &Post{}, "AccountId", "Id", false
+	})
+	if _, err := r.DeleteAll(context.Background(), joined); err == nil {
+		t.Fatalf("a join must be rejected")
+	}
+	limited := From[Account](r).Where(P("Name", "accounts", Like, "test%")).Limit(1)
+	if _, err := r.DeleteAll(context.Background(), limited); err == nil {
+		t.Fatalf("a limit must be rejected")
+	}
+	ordered := From[Account](r).OrderBy(Col("Id", "accounts"), DESC)
+	if _, err := r.UpdateAll(context.Background(), ordered, &Updates{Set: map[string]interface{}{"Name": "x"}}); err == nil {
+		t.Fatalf("an order by must be rejected")
+	}
+}
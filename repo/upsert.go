@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+// OnConflict tells an insert what to do with a row conflicting with an existing one,
+// only the fields cast by the changeset are written.
+type OnConflict struct {
+	replaceAll bool
+	fields     []string
+	incs       []string
+	incBy      []interface{}
+	target     []string
+}
+
+// OnConflictNothing keeps the existing row.
+func OnConflictNothing() *OnConflict {
+	return &OnConflict{}
+}
+
+// OnConflictReplaceAll overwrites the existing row with every cast field.
+func OnConflictReplaceAll() *OnConflict {
+	return &OnConflict{replaceAll: true}
+}
+
+// OnConflictReplace overwrites fields of the existing row, the fields which aren't cast are kept.
+func OnConflictReplace(fields ...string) *OnConflict {
+	return &OnConflict{fields: fields}
+}
+
+// OnConflictInc increments field of the existing row by by.
+func OnConflictInc(field string, by interface{}) *OnConflict {
+	return OnConflictNothing().Inc(field, by)
+}
+
+func (c *OnConflict) Inc(field string, by interface{}) *OnConflict {
+	c.incs = append(c.incs, field)
+	c.incBy = append(c.incBy, by)
+	return c
+}
+
+// Target sets the conflicting fields of postgres and sqlite. Without target any conflict keeps the
+// existing row and replacing or incrementing fields fails. MySQL ignores it.
+func (c *OnConflict) Target(fields ...string) *OnConflict {
+	c.target = fields
+	return c
+}
+
+// conflictClause renders c for an insert of the cast fields of cs.
+func (r *Repo) conflictClause(c *OnConflict, cs *changeset.ChangeSet, fields []string) (string, []interface{}, error) {
+	target := []string{}
+	for _, field := range c.target {
+		target = append(target, cs.Column(field))
+	}
+	key := ""
+	if pk := generatedKey(cs); pk != nil {
+		key = pk.Column
+	}
+	replace := []string{}
+	for _, field := range fields {
+		if c.replaceAll || contains(c.fields, field) {
+			replace = append(replace, cs.Column(field))
+		}
+	}
+	inc := make([]string, len(c.incs))
+	for i, field := range c.incs {
+		inc[i] = cs.Column(field)
+	}
+	clause, err := r.dialect.Upsert(cs.TableName(), target, replace, inc, key)
+	if err != nil {
+		return "", nil, err
+	}
+	return clause, append([]interface{}{}, c.incBy...), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
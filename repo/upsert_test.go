@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DSA-JSC/GoEcto/changeset"
+)
+
+func TestUpsertQuery(t *testing.T) {
+	for _, tc := range []struct {
+		dialect    Dialect
+		onConflict *OnConflict
+		want       string
+	}{
+		{MySQL, OnConflictNothing(), "INSERT INTO `accounts` (`Name`, `Email`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `Id` = LAST_INSERT_ID(`Id`)"},
+		{MySQL, OnConflictReplaceAll(), "INSERT INTO `accounts` (`Name`, `Email`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `Name` = VALUES(`Name`), `Email` = VALUES(`Email`), `Id` = LAST_INSERT_ID(`Id`)"},
+		{Postgres, OnConflictReplace("Name", "Password").Target("Email"), `INSERT INTO "accounts" ("Name", "Email") VALUES ($1, $2) ON CONFLICT ("Email") DO UPDATE SET "Name" = EXCLUDED."Name" RETURNING "Id"`},
+		{Postgres, OnConflictNothing(), `INSERT INTO "accounts" ("Name", "Email") VALUES ($1, $2) ON CONFLICT DO NOTHING RETURNING "Id"`},
+		{SQLite, OnConflictInc("Logins", 1).Target("Email"), `INSERT INTO "accounts" ("Name", "Email") VALUES (?, ?) ON CONFLICT ("Email") DO UPDATE SET "Logins" = "accounts"."Logins" + ? RETURNING "Id"`},
+	} {
+		r := NewRepoDB(nil, tc.dialect)
+		query, args, err := r.insertQuery(newAccount("ann"), tc.onConflict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != tc.want {
+			t.Fatalf("%v: got %q, want %q", tc.dialect.Name(), query, tc.want)
+		}
+		if want := 2 + len(tc.onConflict.incBy); len(args) != want {
+			t.Fatalf("%v: got %v args, want %v", tc.dialect.Name(), len(args), want)
+		}
+	}
+}
+
+func TestUpsertWithoutTarget(t *testing.T) {
+	for _, d := range []Dialect{Postgres, SQLite} {
+		r := NewRepoDB(nil, d)
+		if _, _, err := r.insertQuery(newAccount("ann"), OnConflictReplace("Name")); err == nil {
+			t.Fatalf("%v: an update without target must fail", d.Name())
+		}
+		if _, _, err := r.insertQuery(newAccount("ann"), OnConflictInc("Logins", 1)); err == nil {
+			t.Fatalf("%v: an increment without target must fail", d.Name())
+		}
+	}
+	r := NewRepoDB(nil, MySQL)
+	country := changeset.CastValues(&Country{}, map[string]interface{}{"Code": "fr", "Name": "France"})
+	if _, _, err := r.insertQuery(country, OnConflictNothing()); err == nil {
+		t.Fatalf("keeping a row without generated key nor target must fail on mysql")
+	}
+	query, _, err := r.insertQuery(country, OnConflictNothing().Target("Code"))
+	if want := "INSERT INTO `countries` (`Code`, `Name`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `Code` = `Code`"; err != nil || query != want {
+		t.Fatalf("got %q %v, want %q", query, err, want)
+	}
+}
+
+func TestUpsertSetsKey(t *testing.T) {
+	r, _ := newFakeRepo(MySQL, func(query string, args []interface{}) *fakeResult {
+		// the id of the existing row read back by LAST_INSERT_ID
+		return &fakeResult{rowsAffected: 2, lastInsertId: 42}
+	})
+	defer r.Close()
+	ctx := context.Background()
+	cs := newAccount("ann")
+	if err := r.Save(ctx, cs, OnConflictReplace("Name")); err != nil {
+		t.Fatal(err)
+	}
+	if id := cs.ReflectSchema.FieldByName("Id").Uint(); id != 42 {
+		t.Fatalf("got id %v, want 42", id)
+	}
+	country := &Country{}
+	cs = changeset.CastValues(country, map[string]interface{}{"Code": "fr", "Name": "France"})
+	if err := r.Save(ctx, cs, OnConflictReplace("Name")); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := r.OpenTx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := r.SaveTx(ctx, cs, tx, OnConflictReplace("Name")); err != nil {
+		t.Fatal(err)
+	}
+	if country.Code != "fr" {
+		t.Fatalf("a declared key must be kept, got %q", country.Code)
+	}
+}
+
+func TestUpsertKeepsExistingRow(t *testing.T) {
+	for _, d := range []Dialect{Postgres, SQLite} {
+		r, db := newFakeRepo(d, func(query string, args []interface{}) *fakeResult {
+			if strings.HasSuffix(query, `RETURNING "Id"`) {
+				return rowsOf("Id")
+			}
+			// last_insert_rowid() still holds the id of an earlier insert
+			return &fakeResult{lastInsertId: 77}
+		})
+		cs := newAccount("ann")
+		if err := r.Save(context.Background(), cs, OnConflictNothing().Target("Email")); err != nil {
+			t.Fatal(err)
+		}
+		if id := cs.ReflectSchema.FieldByName("Id").Uint(); id != 0 || cs.ActionRepo != changeset.ActionInsert {
+			t.Fatalf("%v: unexpected id %v after %q", d.Name(), id, db.Queries())
+		}
+		r.Close()
+	}
+}
+
+func TestInsertAllUpsert(t *testing.T) {
+	r, db := newFakeRepo(MySQL, nil)
+	defer r.Close()
+	css := []*changeset.ChangeSet{newAccount("ann"), newAccount("bob")}
+	ctx := context.Background()
+	if _, _, err := r.InsertAll(ctx, &Account{}, css, &InsertAllOptions{Returning: true, OnConflict: OnConflictNothing()}); err == nil {
+		t.Fatalf("Returning with OnConflict must be rejected")
+	}
+	if _, _, err := r.InsertAll(ctx, &Account{}, css, &InsertAllOptions{OnConflict: OnConflictReplace("Name")}); err != nil {
+		t.Fatal(err)
+	}
+	want := "INSERT INTO `accounts` (`Email`, `Name`) VALUES (?, ?), (?, ?) ON DUPLICATE KEY UPDATE `Name` = VALUES(`Name`), `Id` = LAST_INSERT_ID(`Id`)"
+	if got := db.Queries(); len(got) != 1 || got[0] != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}